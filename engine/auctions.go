@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// rankedBids returns the round's bids with a candidate block, highest first.
+// Equal bids keep their submission order, so the earliest bidder wins ties
+// without consulting the RNG.
+func rankedBids(round Round) []BidItem {
+	proposers := make(map[string]bool, len(round.Candidates))
+	for _, block := range round.Candidates {
+		proposers[block.Proposer] = true
+	}
+	ranked := make([]BidItem, 0, len(round.Bids))
+	for _, bidItem := range round.Bids {
+		if bidItem.Bid > 0 && proposers[bidItem.NodeAddress] {
+			ranked = append(ranked, bidItem)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Bid > ranked[j].Bid
+	})
+	return ranked
+}
+
+// SecondPrice is a deterministic Vickrey auction: the highest bid wins and
+// pays the second highest bid, or the reserve if that is higher.
+type SecondPrice struct{}
+
+// NewSecondPrice returns the deterministic second-price auction.
+func NewSecondPrice() *SecondPrice {
+	return &SecondPrice{}
+}
+
+// Name implements SelectionMechanism.
+func (a *SecondPrice) Name() string {
+	return "second-price"
+}
+
+// Select implements SelectionMechanism.
+func (a *SecondPrice) Select(round Round, rng *rand.Rand) Outcome {
+	ranked := rankedBids(round)
+	if len(ranked) == 0 {
+		return Outcome{}
+	}
+	winner, price := ranked[0].NodeAddress, 0
+	if len(ranked) > 1 {
+		price = ranked[1].Bid
+	}
+	price = withReserve(price, round.Reserve)
+	return Outcome{Winner: winner, Price: price, Payments: map[string]int{winner: price}}
+}
+
+// FirstPrice is a sealed first-price auction: the highest bid wins and pays
+// what it bid.
+type FirstPrice struct{}
+
+// NewFirstPrice returns the first-price auction.
+func NewFirstPrice() *FirstPrice {
+	return &FirstPrice{}
+}
+
+// Name implements SelectionMechanism.
+func (a *FirstPrice) Name() string {
+	return "first-price"
+}
+
+// Select implements SelectionMechanism.
+func (a *FirstPrice) Select(round Round, rng *rand.Rand) Outcome {
+	ranked := rankedBids(round)
+	if len(ranked) == 0 {
+		return Outcome{}
+	}
+	winner := ranked[0]
+	return Outcome{Winner: winner.NodeAddress, Price: winner.Bid, Payments: map[string]int{winner.NodeAddress: winner.Bid}}
+}
+
+// AllPay is an all-pay auction: the highest bid wins and every bidder pays
+// its own bid, win or lose.
+type AllPay struct{}
+
+// NewAllPay returns the all-pay auction.
+func NewAllPay() *AllPay {
+	return &AllPay{}
+}
+
+// Name implements SelectionMechanism.
+func (a *AllPay) Name() string {
+	return "all-pay"
+}
+
+// Select implements SelectionMechanism.
+func (a *AllPay) Select(round Round, rng *rand.Rand) Outcome {
+	outcome := Outcome{Payments: escrowedBids(round.Bids)}
+	ranked := rankedBids(round)
+	if len(ranked) == 0 {
+		return outcome
+	}
+	outcome.Winner, outcome.Price = ranked[0].NodeAddress, ranked[0].Bid
+	return outcome
+}
+
+// EnglishClock is an ascending clock auction run by proxy: each bid is the
+// highest price its validator stays in for. The clock rises by Increment
+// until at most one validator is left, who pays the clock price at that
+// point, never more than its bid.
+type EnglishClock struct {
+	Increment int
+}
+
+// NewEnglishClock returns an English clock auction rising by increment.
+func NewEnglishClock(increment int) *EnglishClock {
+	if increment < 1 {
+		increment = 1
+	}
+	return &EnglishClock{Increment: increment}
+}
+
+// Name implements SelectionMechanism.
+func (a *EnglishClock) Name() string {
+	return "english"
+}
+
+// Select implements SelectionMechanism.
+func (a *EnglishClock) Select(round Round, rng *rand.Rand) Outcome {
+	ranked := rankedBids(round)
+	if len(ranked) == 0 {
+		return Outcome{}
+	}
+	winner := ranked[0]
+	price := 0
+	if len(ranked) > 1 {
+		// The runner-up drops out at the first clock tick above its bid.
+		// When the top bids tie, everyone drops at the same tick and the
+		// earliest bidder wins at the last price they all accepted.
+		runnerUp := ranked[1].Bid
+		price = (runnerUp/a.Increment + 1) * a.Increment
+		if runnerUp == winner.Bid {
+			price = runnerUp / a.Increment * a.Increment
+		}
+	}
+	// The clock starts at the reserve.
+	price = withReserve(price, round.Reserve)
+	if price > winner.Bid {
+		price = winner.Bid
+	}
+	return Outcome{Winner: winner.NodeAddress, Price: price, Payments: map[string]int{winner.NodeAddress: price}}
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+// auctionRound is a round in which every bidder proposed a block, except
+// those listed in silent.
+func auctionRound(reserve int, bids []BidItem, silent ...string) Round {
+	round := Round{Bids: bids, Reserve: reserve}
+	skip := make(map[string]bool, len(silent))
+	for _, address := range silent {
+		skip[address] = true
+	}
+	for _, bidItem := range bids {
+		if !skip[bidItem.NodeAddress] {
+			round.Candidates = append(round.Candidates, Block{Proposer: bidItem.NodeAddress})
+		}
+	}
+	return round
+}
+
+func TestAuctions(t *testing.T) {
+	abc := []BidItem{{"a", 30}, {"b", 20}, {"c", 10}}
+	tests := []struct {
+		name      string
+		mechanism SelectionMechanism
+		bids      []BidItem
+		reserve   int
+		silent    []string
+		winner    string
+		price     int
+		payments  map[string]int
+	}{
+		{"second price: pays the runner-up", NewSecondPrice(), abc, 0, nil, "a", 20, map[string]int{"a": 20}},
+		{"second price: reserve above the runner-up", NewSecondPrice(), abc, 25, nil, "a", 25, map[string]int{"a": 25}},
+		{"second price: lone bidder pays the reserve", NewSecondPrice(), []BidItem{{"a", 30}}, 5, nil, "a", 5, map[string]int{"a": 5}},
+		{"second price: tie goes to the earliest bid", NewSecondPrice(), []BidItem{{"b", 20}, {"a", 20}}, 0, nil, "b", 20, map[string]int{"b": 20}},
+		{"second price: bidders without a block are ignored", NewSecondPrice(), abc, 0, []string{"a"}, "b", 10, map[string]int{"b": 10}},
+		{"second price: no bids", NewSecondPrice(), nil, 5, nil, "", 0, nil},
+
+		{"first price: pays its own bid", NewFirstPrice(), abc, 0, nil, "a", 30, map[string]int{"a": 30}},
+		{"first price: tie goes to the earliest bid", NewFirstPrice(), []BidItem{{"b", 20}, {"a", 20}}, 0, nil, "b", 20, map[string]int{"b": 20}},
+		{"first price: no candidates", NewFirstPrice(), abc, 0, []string{"a", "b", "c"}, "", 0, nil},
+
+		{"all-pay: everyone pays", NewAllPay(), abc, 0, nil, "a", 30, map[string]int{"a": 30, "b": 20, "c": 10}},
+		{"all-pay: losers without a block still pay", NewAllPay(), abc, 0, []string{"a"}, "b", 20, map[string]int{"a": 30, "b": 20, "c": 10}},
+		{"all-pay: no candidates", NewAllPay(), abc, 0, []string{"a", "b", "c"}, "", 0, map[string]int{"a": 30, "b": 20, "c": 10}},
+
+		{"english: one tick above the runner-up", NewEnglishClock(5), abc, 0, nil, "a", 25, map[string]int{"a": 25}},
+		{"english: runner-up between ticks", NewEnglishClock(5), []BidItem{{"a", 30}, {"b", 22}}, 0, nil, "a", 25, map[string]int{"a": 25}},
+		{"english: capped at the winning bid", NewEnglishClock(5), []BidItem{{"a", 23}, {"b", 22}}, 0, nil, "a", 23, map[string]int{"a": 23}},
+		{"english: tie stops at the last common tick", NewEnglishClock(5), []BidItem{{"a", 22}, {"b", 22}}, 0, nil, "a", 20, map[string]int{"a": 20}},
+		{"english: clock starts at the reserve", NewEnglishClock(5), []BidItem{{"a", 30}, {"b", 10}}, 18, nil, "a", 18, map[string]int{"a": 18}},
+		{"english: lone bidder pays the reserve", NewEnglishClock(5), []BidItem{{"a", 30}}, 12, nil, "a", 12, map[string]int{"a": 12}},
+		{"english: increment below 1 is 1", NewEnglishClock(0), abc, 0, nil, "a", 21, map[string]int{"a": 21}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome := tt.mechanism.Select(auctionRound(tt.reserve, tt.bids, tt.silent...), nil)
+			if outcome.Winner != tt.winner || outcome.Price != tt.price {
+				t.Fatalf("winner %q at %d, want %q at %d", outcome.Winner, outcome.Price, tt.winner, tt.price)
+			}
+			if !reflect.DeepEqual(outcome.Payments, tt.payments) {
+				t.Fatalf("payments %v, want %v", outcome.Payments, tt.payments)
+			}
+		})
+	}
+}
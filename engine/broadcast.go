@@ -0,0 +1,166 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+)
+
+// OverflowPolicy decides what happens to a subscriber whose queue is full
+// when a new round event is published.
+type OverflowPolicy int
+
+const (
+	// DisconnectSlow unsubscribes the slow consumer and closes its queue, so
+	// every subscriber that stays connected has seen every event.
+	DisconnectSlow OverflowPolicy = iota
+	// DropOldest discards the oldest queued event to make room.
+	DropOldest
+)
+
+// ParseOverflowPolicy parses "disconnect" or "drop".
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	switch name {
+	case "disconnect":
+		return DisconnectSlow, nil
+	case "drop":
+		return DropOldest, nil
+	default:
+		return 0, fmt.Errorf("unknown slow consumer policy %q (available: disconnect, drop)", name)
+	}
+}
+
+func (p OverflowPolicy) String() string {
+	if p == DropOldest {
+		return "drop"
+	}
+	return "disconnect"
+}
+
+// Broadcaster fans each round event out to every subscriber through a
+// buffered per-subscriber queue. Publish never blocks, so a stalled or
+// disconnected validator cannot hold up the round loop.
+type Broadcaster struct {
+	mu        sync.Mutex
+	subs      map[int]*Subscription
+	nextID    int
+	queueSize int
+	policy    OverflowPolicy
+}
+
+// Subscription is one subscriber's queue. C is closed when the subscriber
+// is unsubscribed, either by Close or by the DisconnectSlow policy.
+type Subscription struct {
+	C <-chan RoundEvent
+
+	id      int
+	ch      chan RoundEvent
+	b       *Broadcaster
+	dropped int
+	// disconnected is called when the DisconnectSlow policy drops it.
+	disconnected func()
+}
+
+// NewBroadcaster creates a broadcaster whose subscribers each buffer up to
+// queueSize events.
+func NewBroadcaster(queueSize int, policy OverflowPolicy) *Broadcaster {
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+	return &Broadcaster{
+		subs:      make(map[int]*Subscription),
+		queueSize: queueSize,
+		policy:    policy,
+	}
+}
+
+// Subscribe registers a new subscriber. Only events published after this
+// call are delivered to it.
+func (b *Broadcaster) Subscribe() *Subscription {
+	return b.SubscribeFunc(nil)
+}
+
+// SubscribeFunc is Subscribe with a function called, without the
+// broadcaster's lock held, when the DisconnectSlow policy drops the
+// subscriber. A consumer that falls behind because it is blocked elsewhere,
+// such as on a write to a stalled connection, never sees C close; the
+// function lets the caller unblock it.
+func (b *Broadcaster) SubscribeFunc(disconnected func()) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan RoundEvent, b.queueSize)
+	sub := &Subscription{C: ch, id: b.nextID, ch: ch, b: b, disconnected: disconnected}
+	b.subs[sub.id] = sub
+	b.nextID++
+	return sub
+}
+
+// Close unsubscribes and closes C. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	s.b.remove(s)
+}
+
+// Dropped returns how many events the DropOldest policy discarded for this
+// subscriber.
+func (s *Subscription) Dropped() int {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	return s.dropped
+}
+
+func (b *Broadcaster) remove(sub *Subscription) {
+	if _, ok := b.subs[sub.id]; !ok {
+		return
+	}
+	delete(b.subs, sub.id)
+	close(sub.ch)
+}
+
+// Len returns the number of current subscribers.
+func (b *Broadcaster) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Publish queues event exactly once for every current subscriber. It
+// returns how many subscribers received it and how many were disconnected or
+// had an older event dropped because their queue was full.
+func (b *Broadcaster) Publish(event RoundEvent) (delivered, overflowed int) {
+	b.mu.Lock()
+	var disconnected []func()
+	for _, sub := range b.subs {
+		select {
+		case sub.ch <- event:
+			delivered++
+			continue
+		default:
+		}
+
+		overflowed++
+		switch b.policy {
+		case DropOldest:
+			select {
+			case <-sub.ch:
+				sub.dropped++
+			default:
+			}
+			// Publish is the only sender and holds the lock, so there is
+			// room now even if the consumer raced us for the oldest item.
+			sub.ch <- event
+			delivered++
+		default:
+			b.remove(sub)
+			if sub.disconnected != nil {
+				disconnected = append(disconnected, sub.disconnected)
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	for _, f := range disconnected {
+		f()
+	}
+	return delivered, overflowed
+}
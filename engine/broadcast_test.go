@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"sync"
+	"testing"
+)
+
+// roundEvent is the event announcing that round id opened.
+func roundEvent(id int) RoundEvent {
+	return RoundEvent{Phase: PhaseOpen, Round: RoundInfo{ID: id}}
+}
+
+// drain returns the rounds of every event queued for sub, without waiting.
+func drain(sub *Subscription) (rounds []int, closed bool) {
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				return rounds, true
+			}
+			rounds = append(rounds, event.Round.ID)
+		default:
+			return rounds, false
+		}
+	}
+}
+
+func TestBroadcasterDelivery(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    OverflowPolicy
+		queueSize int
+		published int
+		// want is what a subscriber that never reads finds in its queue.
+		want          []int
+		closed        bool
+		delivered     int
+		overflowed    int
+		dropped       int
+		subscribedEnd int
+	}{
+		{"room for every event", DisconnectSlow, 4, 3, []int{1, 2, 3}, false, 6, 0, 0, 2},
+		{"disconnect the slow subscriber", DisconnectSlow, 2, 3, []int{1, 2}, true, 5, 1, 0, 1},
+		{"drop the oldest event", DropOldest, 2, 4, []int{3, 4}, false, 8, 2, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBroadcaster(tt.queueSize, tt.policy)
+			slow, fast := b.Subscribe(), b.Subscribe()
+			var fastGot []int
+			delivered, overflowed := 0, 0
+			for id := 1; id <= tt.published; id++ {
+				d, o := b.Publish(roundEvent(id))
+				delivered += d
+				overflowed += o
+				rounds, _ := drain(fast)
+				fastGot = append(fastGot, rounds...)
+			}
+
+			got, closed := drain(slow)
+			if !equalInts(got, tt.want) || closed != tt.closed {
+				t.Fatalf("slow subscriber got %v (closed %v), want %v (closed %v)", got, closed, tt.want, tt.closed)
+			}
+			if len(fastGot) != tt.published {
+				t.Fatalf("fast subscriber got %v, want each of %d events once", fastGot, tt.published)
+			}
+			for i, id := range fastGot {
+				if id != i+1 {
+					t.Fatalf("fast subscriber got %v, want each event once in order", fastGot)
+				}
+			}
+			if delivered != tt.delivered || overflowed != tt.overflowed {
+				t.Fatalf("delivered %d and overflowed %d, want %d and %d", delivered, overflowed, tt.delivered, tt.overflowed)
+			}
+			if slow.Dropped() != tt.dropped {
+				t.Fatalf("dropped %d, want %d", slow.Dropped(), tt.dropped)
+			}
+			if b.Len() != tt.subscribedEnd {
+				t.Fatalf("%d subscribers left, want %d", b.Len(), tt.subscribedEnd)
+			}
+		})
+	}
+}
+
+func TestBroadcasterSubscribeAndClose(t *testing.T) {
+	b := NewBroadcaster(4, DisconnectSlow)
+	early := b.Subscribe()
+	b.Publish(roundEvent(1))
+	late := b.Subscribe()
+	b.Publish(roundEvent(2))
+
+	if got, _ := drain(early); !equalInts(got, []int{1, 2}) {
+		t.Fatalf("early subscriber got %v", got)
+	}
+	if got, _ := drain(late); !equalInts(got, []int{2}) {
+		t.Fatalf("late subscriber got %v, want only events after it subscribed", got)
+	}
+
+	late.Close()
+	late.Close()
+	if _, closed := drain(late); !closed {
+		t.Fatal("closed subscription still open")
+	}
+	if delivered, _ := b.Publish(roundEvent(3)); delivered != 1 {
+		t.Fatalf("event delivered %d times after one subscriber left", delivered)
+	}
+}
+
+func TestBroadcasterDisconnectedFunc(t *testing.T) {
+	tests := []struct {
+		policy OverflowPolicy
+		want   int
+	}{
+		{DisconnectSlow, 1},
+		{DropOldest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			b := NewBroadcaster(1, tt.policy)
+			called := 0
+			// The function runs without the broadcaster's lock, so it may
+			// use the broadcaster itself.
+			b.SubscribeFunc(func() { called += 1 + b.Len() })
+			for id := 1; id <= 4; id++ {
+				b.Publish(roundEvent(id))
+			}
+			if called != tt.want {
+				t.Fatalf("disconnected function called %d times, want %d", called, tt.want)
+			}
+		})
+	}
+}
+
+// TestBroadcasterConcurrentReaders checks that subscribers reading while
+// events are published each see every event exactly once.
+func TestBroadcasterConcurrentReaders(t *testing.T) {
+	const events, readers = 200, 8
+	b := NewBroadcaster(events, DisconnectSlow)
+	var wg sync.WaitGroup
+	got := make([][]int, readers)
+	subs := make([]*Subscription, readers)
+	for i := range subs {
+		sub := b.Subscribe()
+		subs[i] = sub
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for event := range sub.C {
+				got[i] = append(got[i], event.Round.ID)
+			}
+		}(i)
+	}
+	for id := 1; id <= events; id++ {
+		if _, overflowed := b.Publish(roundEvent(id)); overflowed > 0 {
+			t.Fatalf("event %d overflowed a queue of %d", id, events)
+		}
+	}
+	for _, sub := range subs {
+		sub.Close()
+	}
+	wg.Wait()
+	for i, rounds := range got {
+		if len(rounds) != events {
+			t.Fatalf("reader %d got %d events, want %d", i, len(rounds), events)
+		}
+		for j, id := range rounds {
+			if id != j+1 {
+				t.Fatalf("reader %d got event %d in place %d", i, id, j+1)
+			}
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
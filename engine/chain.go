@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Block is a single entry in the proof-of-stake chain. Proposer is the
+// validator that submitted the candidate block, Validator is the validator the
+// selection mechanism picked for the round and Transfer is the price it paid.
+// When the round picked a committee, Committee lists every member, Validator
+// first.
+// Round, BidsOpened and BidsClosed identify the auction round that produced
+// the block and Reserve is the reserve price that was in force. Reward is
+// what the winner, or its committee, was paid, Fees the part of it that came from transaction
+// fees, Burned what the round destroyed and Supply the
+// total token supply once the round had settled. Evidence lists the offences
+// slashed since the previous block. Sortition, for rounds run by Sortition,
+// holds what anyone needs to re-verify the selection.
+type Block struct {
+	Index      int
+	Timestamp  string
+	BPM        int
+	Hash       string
+	PrevHash   string
+	Validator  string
+	Committee  []string
+	Proposer   string
+	Transfer   int
+	Round      int
+	BidsOpened string
+	BidsClosed string
+	Reserve    int
+	Reward     int
+	Fees       int
+	Burned     int
+	Supply     int
+	Evidence   []Evidence
+	Sortition  *SortitionRecord
+}
+
+// Chain is the append-only list of accepted blocks. It is safe for concurrent
+// use by the round loop and the connection handlers.
+type Chain struct {
+	mu     sync.Mutex
+	blocks []Block
+}
+
+// NewChain creates a chain holding only the genesis block.
+func NewChain(t time.Time) *Chain {
+	genesisBlock := Block{Index: 0, Timestamp: t.String(), Hash: CalculateBlockHash(Block{})}
+	return &Chain{blocks: []Block{genesisBlock}}
+}
+
+// Genesis returns the first block of the chain.
+func (c *Chain) Genesis() Block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.blocks[0]
+}
+
+// Last returns the current chain tip.
+func (c *Chain) Last() Block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.blocks[len(c.blocks)-1]
+}
+
+// Len returns the number of blocks including genesis.
+func (c *Chain) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.blocks)
+}
+
+// Append adds a block to the tip of the chain.
+func (c *Chain) Append(block Block) {
+	c.mu.Lock()
+	c.blocks = append(c.blocks, block)
+	c.mu.Unlock()
+}
+
+// Blocks returns a copy of every block in the chain.
+func (c *Chain) Blocks() []Block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Block(nil), c.blocks...)
+}
+
+// CalculateHash returns the hex encoded SHA-256 digest of s.
+func CalculateHash(s string) string {
+	h := sha256.New()
+	h.Write([]byte(s))
+	hashed := h.Sum(nil)
+	return hex.EncodeToString(hashed)
+}
+
+// CalculateBlockHash hashes the fields of a block that are fixed when it is
+// proposed. The index and BPM are written in decimal. The original servers
+// used string(n), a single code point that collided for every invalid one,
+// so hashes differ from chains they produced; see the README.
+func CalculateBlockHash(block Block) string {
+	record := strconv.Itoa(block.Index) + block.Timestamp + strconv.Itoa(block.BPM) + block.PrevHash
+	return CalculateHash(record)
+}
+
+// GenerateBlock builds a candidate block on top of oldBlock for the given
+// proposer, stamped with t.
+func GenerateBlock(oldBlock Block, BPM int, address string, t time.Time) Block {
+	var newBlock Block
+
+	newBlock.Index = oldBlock.Index + 1
+	newBlock.Timestamp = t.String()
+	newBlock.BPM = BPM
+	newBlock.PrevHash = oldBlock.Hash
+	newBlock.Hash = CalculateBlockHash(newBlock)
+	newBlock.Proposer = address
+
+	return newBlock
+}
+
+// IsBlockValid reports whether newBlock correctly extends oldBlock.
+func IsBlockValid(newBlock, oldBlock Block) bool {
+	if oldBlock.Index+1 != newBlock.Index {
+		return false
+	}
+
+	if oldBlock.Hash != newBlock.PrevHash {
+		return false
+	}
+
+	if CalculateBlockHash(newBlock) != newBlock.Hash {
+		return false
+	}
+
+	return true
+}
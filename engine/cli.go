@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Main is the entry point shared by the variant binaries. It loads PORT from
+// .env and either serves validators over TCP or, with -simulate, runs an
+// in-process simulation on a virtual clock.
+func Main(cfg Config) {
+	err := godotenv.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.Port = os.Getenv("PORT")
+
+	sim := SimConfig{}
+	var bidIntervalSec float64
+	flag.IntVar(&sim.Rounds, "simulate", 0, "run an in-process simulation of this many rounds instead of the TCP server")
+	flag.IntVar(&sim.Validators, "sim-validators", 10, "number of simulated validators")
+	flag.IntVar(&sim.Balance, "sim-balance", 1000, "initial token balance per simulated validator")
+	flag.IntVar(&sim.BaseCost, "sim-base-cost", 15, "base bidding cost used to derive random bids")
+	flag.IntVar(&sim.MinBPM, "sim-bpm-min", 60, "minimum BPM value submitted by simulated validators")
+	flag.IntVar(&sim.MaxBPM, "sim-bpm-max", 80, "maximum BPM value submitted by simulated validators")
+	flag.IntVar(&sim.OverbidLimit, "sim-overbid-limit", 100, "upper bound for per-validator overbid percentage")
+	flag.Float64Var(&bidIntervalSec, "sim-bid-interval", 0, "virtual seconds between bids from the same validator (default: one round)")
+	flag.Float64Var(&sim.RevealRate, "sim-reveal-rate", 1, "probability that a simulated validator reveals its sealed bid")
+	flag.Float64Var(&sim.BondFraction, "sim-bond", 0.5, "share of its balance each simulated validator bonds under -bonded-stake")
+	flag.IntVar(&sim.Delegators, "sim-delegators", 0, "number of simulated delegators staking through validators under -bonded-stake")
+	flag.Float64Var(&sim.Redelegate, "sim-redelegate", 0, "probability per round that a simulated delegator moves its stake to another validator")
+	flag.Float64Var(&sim.Commission, "sim-commission", 0.2, "highest commission a simulated validator charges its delegators")
+	flag.Float64Var(&sim.Equivocate, "sim-equivocate", 0, "probability that a simulated validator follows its bid with a conflicting one")
+	flag.BoolVar(&sim.Quiet, "sim-quiet", false, "only print the final summary of a simulation")
+	flag.StringVar(&cfg.ExportPath, "export", cfg.ExportPath, "path of the exported blockchain workbook; the blocks are also written as JSON lines next to it, with a .jsonl extension")
+	flag.Int64Var(&cfg.Seed, "seed", defaultSeed(), "seed for the lottery RNG (default: $SEED, else the current time)")
+	flag.IntVar(&cfg.AnnouncementQueue, "announce-queue", 16, "round results buffered per validator before the slow consumer policy applies")
+	flag.BoolVar(&cfg.RequireSignatures, "require-signatures", false, "only accept validators that register an Ed25519 key and sign their bids")
+	flag.IntVar(&cfg.Bids.MaxBid, "max-bid", 0, "largest bid accepted in a round (0: no cap)")
+	flag.IntVar(&cfg.Bids.Granularity, "bid-granularity", 1, "unit bids are counted in; bids must be a multiple of it")
+	flag.IntVar(&cfg.Bids.MinIncrement, "min-increment", 0, "amount an open bid must beat the round's best bid by (0: no minimum)")
+	priceSplit := flag.String("price-split", "1:0:0", "how round payments are divided, as BURN:REDISTRIBUTE:TREASURY fractions adding up to 1")
+	reward := flag.String("reward", "none", "what block winners earn: none, fixed:N, decay:N:HALVING (halves every HALVING blocks) or fees:TXS:MAXFEE (synthetic transaction fees per round)")
+	flag.BoolVar(&cfg.Economics.Bonding.Enabled, "bonded-stake", false, "select winners by bonded stake; validators bond and unbond part of their balance")
+	flag.IntVar(&cfg.Economics.Bonding.UnbondingRounds, "unbonding-rounds", 10, "rounds unbonded stake stays locked before it returns to the balance")
+	slashing := flag.String("slash", "none", "penalties for invalid-block, equivocation and non-reveal, e.g. equivocation=50%+jail:10,non-reveal=5% (N% of stake, jail:N rounds, eject)")
+	reserve := flag.String("reserve", "none", "reserve price: none, static:PRICE or adaptive:PRICE:TARGET (EIP-1559 style, TARGET bids per round)")
+	bidWindowSec := flag.Float64("bid-window", cfg.RoundInterval.Seconds(), "seconds each round stays open for bids")
+	settleDelaySec := flag.Float64("settle-delay", 0, "seconds between a round closing and its settlement")
+	lateBids := flag.String("late-bids", "reject", "what to do with bids that arrive while no round is open: reject or carry")
+	mechanism := flag.String("mechanism", "", "selection mechanism to run instead of the variant's own ("+MechanismNames()+")")
+	flag.Float64Var(&cfg.SortitionSize, "sortition-size", DefaultSortitionSize, "expected number of validators the sortition mechanism selects per round")
+	weighting := flag.String("weighting", "none", "transform lottery weights before the draw: none, or a chain such as cap:500,sqrt of cap:N, sqrt, quadratic, log and coin-age[:MAX]")
+	committee := flag.String("committee", "none", "pick a committee per round: none, sample:K (k draws without replacement from the lottery), kth:K or k+1th:K (uniform-price auction at the k-th or (k+1)-th highest bid)")
+	commitReveal := flag.Bool("commit-reveal", false, "run the mechanism as a sealed-bid auction: commit H(bid||salt) while the round is open, reveal before settlement")
+	forfeit := flag.Int("forfeit", 10, "deposit a sealed bidder forfeits when it does not reveal")
+	genesis := flag.String("genesis", "", "JSON file of genesis stake allocations by public key or address")
+	faucet := flag.Int("faucet", DefaultFaucet, "stake granted to validators without a genesis allocation; 0 rejects them")
+	inactive := flag.String("inactive", "keep", "what to do with accounts of disconnected validators: keep, freeze:N or evict:N (N rounds)")
+	slowPolicy := flag.String("slow-policy", "disconnect", "what to do with validators whose announcement queue is full: disconnect or drop")
+	flag.Parse()
+	sim.BidInterval = time.Duration(bidIntervalSec * float64(time.Second))
+	cfg.RoundInterval = time.Duration(*bidWindowSec * float64(time.Second))
+	cfg.SettlementDelay = time.Duration(*settleDelaySec * float64(time.Second))
+
+	policy, err := ParseOverflowPolicy(*slowPolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.SlowConsumerPolicy = policy
+	if *mechanism != "" {
+		if cfg.Mechanism, err = NewMechanism(*mechanism, cfg); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if cfg.Weighting, err = ParseWeightingPolicy(*weighting); err != nil {
+		log.Fatal(err)
+	}
+	if len(cfg.Weighting) > 0 {
+		if cfg.Mechanism, err = NewWeighted(cfg.Mechanism, cfg.Weighting); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if cfg.Committee, err = ParseCommitteePolicy(*committee); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Committee.Mode != NoCommittee {
+		if cfg.Mechanism, err = NewCommittee(cfg.Mechanism, cfg.Committee); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *commitReveal {
+		cfg.Mechanism = NewCommitReveal(cfg.Mechanism, *forfeit)
+	}
+	if cfg.Bids.Late, err = ParseLatePolicy(*lateBids); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Bids.Reserve, err = ParseReservePolicy(*reserve); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Economics.Split, err = ParsePriceSplit(*priceSplit); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Economics.Reward, err = ParseRewardPolicy(*reward); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Economics.Slashing, err = ParseSlashingPolicy(*slashing); err != nil {
+		log.Fatal(err)
+	}
+	cfg.Stakes = StakePolicy{Stakes: map[string]int{}, Faucet: *faucet}
+	if *genesis != "" {
+		if cfg.Stakes, err = LoadGenesis(*genesis, *faucet); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if cfg.Inactivity, err = ParseInactivityPolicy(*inactive); err != nil {
+		log.Fatal(err)
+	}
+
+	if sim.Rounds > 0 {
+		if sim.Delegators > 0 && sim.Validators <= 0 {
+			log.Fatal("-sim-delegators needs at least one simulated validator to delegate to")
+		}
+		runSimulation(cfg, sim)
+		return
+	}
+
+	server := NewServer(cfg)
+	log.Fatal(server.ListenAndServe())
+}
+
+func defaultSeed() int64 {
+	if value := os.Getenv("SEED"); value != "" {
+		seed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			log.Fatalf("invalid SEED %q: %v", value, err)
+		}
+		return seed
+	}
+	return time.Now().UnixNano()
+}
+
+func runSimulation(cfg Config, sim SimConfig) {
+	if cfg.ExportPath == "" {
+		cfg.ExportPath = "blockchain.xlsx"
+	}
+
+	start := time.Now()
+	simulation := NewSimulation(cfg, sim)
+	report := simulation.Run()
+	log.Printf("Mechanism %s, seed %d", cfg.Mechanism.Name(), cfg.Seed)
+	if cfg.Committee.Mode != NoCommittee {
+		log.Printf("Committee: %s", cfg.Committee)
+	}
+	if len(cfg.Weighting) > 0 {
+		log.Printf("Weighting: %s", cfg.Weighting)
+	}
+	if usesSortition(cfg.Mechanism) {
+		log.Printf("Sortition: %v validators selected per round on average", cfg.SortitionSize)
+	}
+	if cfg.Bids.Reserve.Mode != NoReserve {
+		log.Printf("Reserve price: %s", cfg.Bids.Reserve)
+	}
+	log.Printf("Simulated %d rounds (%d blocks, %v virtual) in %v; final Gini Coefficient: %v",
+		report.Rounds, report.Blocks, report.Elapsed, time.Since(start).Round(time.Millisecond), report.Gini)
+	if sim.Delegators > 0 {
+		log.Printf("Gini Coefficient over all holders, delegators included: %v", report.HolderGini)
+	}
+	log.Printf("Bids: %s", report.Bids)
+	log.Printf("Revenue: %d tokens charged at settlement", report.Bids.Revenue)
+	log.Printf("Supply: %d tokens (%d minted, %d burned, %d slashed, %d in the treasury)", report.Supply, report.Bids.Minted, report.Bids.Burned, report.Bids.Slashed, report.Treasury)
+	log.Printf("Utility: %s", report.Utility)
+	log.Printf("Wins: %s", report.Wins)
+
+	blocks := simulation.Chain().Blocks()
+	if err := ExportBlockchainToExcel(blocks, simulation.RunInfo(), simulation.Ledgers(), cfg.ExportPath); err != nil {
+		log.Fatalf("Error saving to excel: %v", err)
+	}
+	if err := ExportChain(blocks, ChainPath(cfg.ExportPath)); err != nil {
+		log.Fatalf("Error saving the chain: %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CommitteeMode decides how a committee is picked and what its members pay.
+type CommitteeMode int
+
+const (
+	// NoCommittee picks a single winner.
+	NoCommittee CommitteeMode = iota
+	// CommitteeSample draws members one at a time from the wrapped lottery,
+	// without replacement.
+	CommitteeSample
+	// CommitteeKthPrice is a uniform-price auction: the k highest bids win
+	// and every member pays the k-th highest bid.
+	CommitteeKthPrice
+	// CommitteeNextPrice is a uniform-price auction in which every member
+	// pays the (k+1)-th highest bid, the generalised Vickrey price.
+	CommitteeNextPrice
+)
+
+var committeeModes = map[string]CommitteeMode{
+	"sample": CommitteeSample,
+	"kth":    CommitteeKthPrice,
+	"k+1th":  CommitteeNextPrice,
+}
+
+// CommitteePolicy is the committee of Size validators picked every round.
+type CommitteePolicy struct {
+	Mode CommitteeMode
+	Size int
+}
+
+// ParseCommitteePolicy parses "none", "sample:K", "kth:K" or "k+1th:K".
+func ParseCommitteePolicy(spec string) (CommitteePolicy, error) {
+	if spec == "none" || spec == "" {
+		return CommitteePolicy{}, nil
+	}
+	name, size, _ := strings.Cut(spec, ":")
+	mode, ok := committeeModes[name]
+	k, err := strconv.Atoi(size)
+	if !ok || err != nil || k < 1 {
+		return CommitteePolicy{}, fmt.Errorf("committee %q: want none, sample:K, kth:K or k+1th:K with K at least 1", spec)
+	}
+	return CommitteePolicy{Mode: mode, Size: k}, nil
+}
+
+func (p CommitteePolicy) String() string {
+	for name, mode := range committeeModes {
+		if mode == p.Mode {
+			return name + ":" + strconv.Itoa(p.Size)
+		}
+	}
+	return "none"
+}
+
+// committeeLottery is a lottery that can draw several distinct winners.
+type committeeLottery interface {
+	SelectCommittee(round Round, k int, rng *rand.Rand) Outcome
+}
+
+// Committee runs another mechanism so that every round picks a committee of
+// validators instead of a single winner. The first member is the leader,
+// whose candidate block is appended; the block records every member and the
+// reward is split between them.
+type Committee struct {
+	inner  SelectionMechanism
+	policy CommitteePolicy
+}
+
+// NewCommittee wraps inner to pick committees under policy. Sampling needs
+// inner to be one of the lotteries or sortition; the uniform-price auctions
+// rank bids whatever inner is, other than sortition.
+func NewCommittee(inner SelectionMechanism, policy CommitteePolicy) (*Committee, error) {
+	if _, ok := inner.(committeeLottery); policy.Mode == CommitteeSample && !ok {
+		return nil, fmt.Errorf("committee %s: mechanism %s is not a lottery", policy, inner.Name())
+	}
+	if policy.Mode != CommitteeSample && usesSortition(inner) {
+		return nil, fmt.Errorf("committee %s: sortition committees can only be sampled", policy)
+	}
+	return &Committee{inner: inner, policy: policy}, nil
+}
+
+func (c *Committee) Name() string { return "committee-" + c.inner.Name() }
+
+// Select implements SelectionMechanism.
+func (c *Committee) Select(round Round, rng *rand.Rand) Outcome {
+	if c.policy.Mode == CommitteeSample {
+		return c.inner.(committeeLottery).SelectCommittee(round, c.policy.Size, rng)
+	}
+	ranked := rankedBids(round)
+	if len(ranked) == 0 {
+		return Outcome{}
+	}
+	k := c.policy.Size
+	if k > len(ranked) {
+		k = len(ranked)
+	}
+	price := 0
+	switch {
+	case c.policy.Mode == CommitteeKthPrice:
+		price = ranked[k-1].Bid
+	case k < len(ranked):
+		price = ranked[k].Bid
+	}
+	price = withReserve(price, round.Reserve)
+	outcome := Outcome{Winner: ranked[0].NodeAddress, Price: price, Payments: make(map[string]int, k)}
+	for _, bidItem := range ranked[:k] {
+		outcome.Committee = append(outcome.Committee, bidItem.NodeAddress)
+		outcome.Payments[bidItem.NodeAddress] = price
+	}
+	return outcome
+}
+
+// weightedSample draws up to k distinct addresses, each with probability
+// proportional to its weight among those not drawn yet. The addresses are
+// ordered before drawing, so that the draws depend only on the weights and
+// the random source.
+func weightedSample(weights map[string]int, k int, rng *rand.Rand) []string {
+	keys := make([]string, 0, len(weights))
+	for addr := range weights {
+		keys = append(keys, addr)
+	}
+	sort.Strings(keys)
+	values := make([]int, len(keys))
+	for i, addr := range keys {
+		values[i] = weights[addr]
+	}
+
+	pool := newWeightTree(values)
+	var drawn []string
+	for len(drawn) < k {
+		i := pool.draw(rng)
+		if i < 0 {
+			break
+		}
+		pool.remove(i)
+		drawn = append(drawn, keys[i])
+	}
+	return drawn
+}
+
+// committeeOf returns the members of an outcome: its committee, or just its
+// winner when the mechanism picked one.
+func committeeOf(outcome Outcome) []string {
+	if len(outcome.Committee) > 0 {
+		return outcome.Committee
+	}
+	if outcome.Winner == "" {
+		return nil
+	}
+	return []string{outcome.Winner}
+}
+
+// splitEvenly divides amount between n members, the first ones taking the
+// tokens left over.
+func splitEvenly(amount, n int) []int {
+	shares := make([]int, n)
+	for i := range shares {
+		shares[i] = amount / n
+		if i < amount%n {
+			shares[i]++
+		}
+	}
+	return shares
+}
+
+// memberSet returns the committee members as a set.
+func memberSet(members []string) map[string]bool {
+	set := make(map[string]bool, len(members))
+	for _, addr := range members {
+		set[addr] = true
+	}
+	return set
+}
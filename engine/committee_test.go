@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommitteePolicy(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    CommitteePolicy
+		wantErr bool
+	}{
+		{"none", CommitteePolicy{}, false},
+		{"sample:3", CommitteePolicy{Mode: CommitteeSample, Size: 3}, false},
+		{"kth:4", CommitteePolicy{Mode: CommitteeKthPrice, Size: 4}, false},
+		{"k+1th:1", CommitteePolicy{Mode: CommitteeNextPrice, Size: 1}, false},
+		{"kth", CommitteePolicy{}, true},
+		{"kth:0", CommitteePolicy{}, true},
+		{"kth:-2", CommitteePolicy{}, true},
+		{"kth:two", CommitteePolicy{}, true},
+		{"vote:3", CommitteePolicy{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			policy, err := ParseCommitteePolicy(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if policy != tt.want {
+				t.Fatalf("parsed %+v, want %+v", policy, tt.want)
+			}
+			if policy.String() != tt.spec {
+				t.Fatalf("String() = %q, want %q", policy.String(), tt.spec)
+			}
+		})
+	}
+}
+
+func TestNewCommittee(t *testing.T) {
+	tests := []struct {
+		name    string
+		inner   SelectionMechanism
+		policy  CommitteePolicy
+		wantErr bool
+	}{
+		{"sample a lottery", NewVickreyLottery(), CommitteePolicy{Mode: CommitteeSample, Size: 3}, false},
+		{"sample sortition", NewSortition(3), CommitteePolicy{Mode: CommitteeSample, Size: 3}, false},
+		{"sample an auction", NewSecondPrice(), CommitteePolicy{Mode: CommitteeSample, Size: 3}, true},
+		{"price an auction", NewSecondPrice(), CommitteePolicy{Mode: CommitteeKthPrice, Size: 3}, false},
+		{"price sortition", NewSortition(3), CommitteePolicy{Mode: CommitteeNextPrice, Size: 3}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCommittee(tt.inner, tt.policy); (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCommitteePricing(t *testing.T) {
+	abcd := []BidItem{{"a", 50}, {"b", 40}, {"c", 30}, {"d", 20}}
+	kth := func(k int) CommitteePolicy { return CommitteePolicy{Mode: CommitteeKthPrice, Size: k} }
+	next := func(k int) CommitteePolicy { return CommitteePolicy{Mode: CommitteeNextPrice, Size: k} }
+	tests := []struct {
+		name      string
+		policy    CommitteePolicy
+		bids      []BidItem
+		reserve   int
+		silent    []string
+		committee []string
+		price     int
+	}{
+		{"kth: the k-th bid", kth(2), abcd, 0, nil, []string{"a", "b"}, 40},
+		{"k+1th: the (k+1)-th bid", next(2), abcd, 0, nil, []string{"a", "b"}, 30},
+		{"kth: k of 1 is first price", kth(1), abcd, 0, nil, []string{"a"}, 50},
+		{"k+1th: k of 1 is second price", next(1), abcd, 0, nil, []string{"a"}, 40},
+		{"kth: every bidder wins", kth(4), abcd, 0, nil, []string{"a", "b", "c", "d"}, 20},
+		{"k+1th: every bidder wins at the reserve", next(4), abcd, 15, nil, []string{"a", "b", "c", "d"}, 15},
+		{"kth: fewer bidders than seats", kth(6), abcd, 0, nil, []string{"a", "b", "c", "d"}, 20},
+		{"k+1th: fewer bidders than seats", next(6), abcd, 0, nil, []string{"a", "b", "c", "d"}, 0},
+		{"k+1th: reserve above the (k+1)-th bid", next(3), abcd, 25, nil, []string{"a", "b", "c"}, 25},
+		{"kth: ties keep submission order", kth(2), []BidItem{{"c", 30}, {"a", 30}, {"b", 30}}, 0, nil, []string{"c", "a"}, 30},
+		{"k+1th: bidders without a block are skipped", next(2), abcd, 0, []string{"b"}, []string{"a", "c"}, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			committee, err := NewCommittee(NewSecondPrice(), tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			outcome := committee.Select(auctionRound(tt.reserve, tt.bids, tt.silent...), nil)
+			if !reflect.DeepEqual(outcome.Committee, tt.committee) || outcome.Price != tt.price {
+				t.Fatalf("committee %v at %d, want %v at %d", outcome.Committee, outcome.Price, tt.committee, tt.price)
+			}
+			if outcome.Winner != tt.committee[0] {
+				t.Fatalf("leader %q, want %q", outcome.Winner, tt.committee[0])
+			}
+			if len(outcome.Payments) != len(tt.committee) {
+				t.Fatalf("payments %v, want one for each of %v", outcome.Payments, tt.committee)
+			}
+			for _, member := range tt.committee {
+				if outcome.Payments[member] != tt.price {
+					t.Fatalf("payments %v, want %d from each of %v", outcome.Payments, tt.price, tt.committee)
+				}
+			}
+		})
+	}
+
+	committee, _ := NewCommittee(NewSecondPrice(), kth(2))
+	if outcome := committee.Select(Round{}, nil); !reflect.DeepEqual(outcome, Outcome{}) {
+		t.Fatalf("round without bids selected %+v", outcome)
+	}
+}
+
+func TestSplitEvenly(t *testing.T) {
+	tests := []struct {
+		amount, n int
+		want      []int
+	}{
+		{9, 3, []int{3, 3, 3}},
+		{10, 3, []int{4, 3, 3}},
+		{11, 3, []int{4, 4, 3}},
+		{2, 3, []int{1, 1, 0}},
+		{0, 2, []int{0, 0}},
+		{5, 0, []int{}},
+	}
+	for _, tt := range tests {
+		if got := splitEvenly(tt.amount, tt.n); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitEvenly(%d, %d) = %v, want %v", tt.amount, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package engine
+
+import (
+	"errors"
+	"sort"
+)
+
+var (
+	// ErrDelegator is returned for bids from delegator accounts, which stake
+	// through validators instead of running one.
+	ErrDelegator = errors.New("delegators cannot bid")
+	// ErrNotValidator is returned when stake is delegated to an address that
+	// is not an active validator.
+	ErrNotValidator = errors.New("delegation target is not a validator")
+	// ErrSelfDelegation is returned when an account delegates to itself.
+	ErrSelfDelegation = errors.New("cannot delegate to yourself")
+	// ErrInsufficientDelegation is returned when a delegator undelegates or
+	// redelegates more than it has delegated to the validator.
+	ErrInsufficientDelegation = errors.New("more than your delegation to the validator")
+	// ErrBadCommission is returned for commission rates outside 0-10000
+	// basis points.
+	ErrBadCommission = errors.New("commission must be between 0 and 10000 basis points")
+)
+
+// MaxCommission is a commission of 100%, in basis points.
+const MaxCommission = 10000
+
+// SetDelegator marks an account as a delegator: it cannot bid, and it takes
+// part in selection only through the validators it delegates to.
+func (r *Registry) SetDelegator(address string) {
+	r.mu.Lock()
+	if node, ok := r.nodes[address]; ok {
+		node.Delegator = true
+	}
+	r.mu.Unlock()
+}
+
+// Delegate moves amount from a delegator's balance into its delegation to
+// validator.
+func (r *Registry) Delegate(address, validator string, amount int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, ok := r.nodes[address]
+	if !ok {
+		return ErrUnknownValidator
+	}
+	if err := r.delegable(address, validator); err != nil {
+		return err
+	}
+	if node.Balance < amount {
+		return ErrInsufficientBalance
+	}
+	node.Balance -= amount
+	if node.Delegations == nil {
+		node.Delegations = make(map[string]int)
+	}
+	node.Delegations[validator] += amount
+	return nil
+}
+
+// Undelegate moves amount of a delegation to validator into the delegator's
+// unbonding queue, to be released once round release has settled.
+func (r *Registry) Undelegate(address, validator string, amount, release int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, ok := r.nodes[address]
+	if !ok {
+		return ErrUnknownValidator
+	}
+	if node.Delegations[validator] < amount {
+		return ErrInsufficientDelegation
+	}
+	node.undelegate(validator, amount)
+	node.Unbonding = append(node.Unbonding, Unbonding{Amount: amount, Release: release})
+	return nil
+}
+
+// Redelegate moves amount of a delegation from one validator to another at
+// once, without passing through the unbonding queue.
+func (r *Registry) Redelegate(address, from, to string, amount int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, ok := r.nodes[address]
+	if !ok {
+		return ErrUnknownValidator
+	}
+	if node.Delegations[from] < amount {
+		return ErrInsufficientDelegation
+	}
+	if err := r.delegable(address, to); err != nil {
+		return err
+	}
+	node.undelegate(from, amount)
+	if node.Delegations == nil {
+		node.Delegations = make(map[string]int)
+	}
+	node.Delegations[to] += amount
+	return nil
+}
+
+// SetCommission sets the share of its delegators' rewards a validator keeps,
+// in basis points.
+func (r *Registry) SetCommission(address string, commission int) error {
+	if commission < 0 || commission > MaxCommission {
+		return ErrBadCommission
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, ok := r.nodes[address]
+	if !ok {
+		return ErrUnknownValidator
+	}
+	if node.Delegator {
+		return ErrDelegator
+	}
+	node.Commission = commission
+	return nil
+}
+
+// Delegated returns the stake delegated to every validator that has any.
+func (r *Registry) Delegated() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.delegated()
+}
+
+func (r *Registry) delegated() map[string]int {
+	delegated := make(map[string]int)
+	for _, node := range r.nodes {
+		for validator, amount := range node.Delegations {
+			delegated[validator] += amount
+		}
+	}
+	return delegated
+}
+
+// Delegations returns the total an account has delegated.
+func (r *Registry) Delegations(address string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, ok := r.nodes[address]
+	if !ok {
+		return 0
+	}
+	return node.delegations()
+}
+
+// ValidatorBalances returns the holdings of every active validator, leaving
+// out delegators.
+func (r *Registry) ValidatorBalances() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	balances := make([]int, 0, len(r.nodes))
+	for _, node := range r.nodes {
+		if node.Frozen || node.Delegator {
+			continue
+		}
+		balances = append(balances, node.holdings())
+	}
+	return balances
+}
+
+// ValidatorLedgers returns the ledger of every validator by address, leaving
+// out delegators.
+func (r *Registry) ValidatorLedgers() map[string]Ledger {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ledgers := make(map[string]Ledger, len(r.nodes))
+	for address, node := range r.nodes {
+		if !node.Delegator {
+			ledgers[address] = node.Ledger
+		}
+	}
+	return ledgers
+}
+
+// HasDelegators reports whether any account is a delegator.
+func (r *Registry) HasDelegators() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, node := range r.nodes {
+		if node.Delegator {
+			return true
+		}
+	}
+	return false
+}
+
+// delegable reports whether address may delegate stake to validator. A
+// validator's own stake is bonded, not delegated: delegating to itself would
+// count it as both when its reward is shared. The caller holds r.mu.
+func (r *Registry) delegable(address, validator string) error {
+	if address == validator {
+		return ErrSelfDelegation
+	}
+	node, ok := r.nodes[validator]
+	if !ok || node.Delegator || node.Ejected {
+		return ErrNotValidator
+	}
+	return nil
+}
+
+func (n *Node) undelegate(validator string, amount int) {
+	n.Delegations[validator] -= amount
+	if n.Delegations[validator] == 0 {
+		delete(n.Delegations, validator)
+	}
+}
+
+func (n *Node) delegations() int {
+	total := 0
+	for _, amount := range n.Delegations {
+		total += amount
+	}
+	return total
+}
+
+// shareReward splits a block reward between the winning validator and its
+// delegators. The delegators' part is proportional to their share of the
+// validator's bonded plus delegated stake; the validator keeps its commission
+// on it. The delegators' part is divided by delegation with the same largest
+// remainder rounding as redistributed payments. The caller holds r.mu.
+func (r *Registry) shareReward(winner *Node, amount int) map[*Node]int {
+	type part struct {
+		node      *Node
+		stake     int
+		remainder int
+	}
+	var parts []part
+	delegated := 0
+	for _, node := range r.nodes {
+		if stake := node.Delegations[winner.Address]; stake > 0 {
+			parts = append(parts, part{node: node, stake: stake})
+			delegated += stake
+		}
+	}
+	shares := map[*Node]int{winner: amount}
+	if delegated == 0 || amount <= 0 {
+		return shares
+	}
+	pool := amount * delegated / (winner.Bonded + delegated)
+	pool -= pool * winner.Commission / MaxCommission
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].node.Address < parts[j].node.Address
+	})
+	left := pool
+	for i := range parts {
+		share := pool * parts[i].stake / delegated
+		shares[parts[i].node] = share
+		parts[i].remainder = pool * parts[i].stake % delegated
+		left -= share
+	}
+	sort.SliceStable(parts, func(i, j int) bool {
+		return parts[i].remainder > parts[j].remainder
+	})
+	for i := 0; i < left; i++ {
+		shares[parts[i].node]++
+	}
+	shares[winner] = amount - pool
+	return shares
+}
+
+// delegate handles the Delegate, Undelegate, Redelegate and SetCommission
+// requests. The caller has authorized req and checked that bonding is
+// enabled.
+func (m *RoundManager) delegate(req BidRequest) error {
+	if req.Kind == SetCommission {
+		return m.registry.SetCommission(req.Address, req.Bid)
+	}
+	if req.Bid <= 0 {
+		return ErrNonPositiveBid
+	}
+	switch req.Kind {
+	case Delegate:
+		return m.registry.Delegate(req.Address, req.Validator, req.Bid)
+	case Undelegate:
+		return m.registry.Undelegate(req.Address, req.Validator, req.Bid, m.round+m.economics.Bonding.UnbondingRounds)
+	default:
+		return m.registry.Redelegate(req.Address, req.From, req.Validator, req.Bid)
+	}
+}
@@ -0,0 +1,259 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+// delegationRegistry registers validators v1 and v2, each with 100 bonded,
+// and delegators d1 and d2 with 1000 each.
+func delegationRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r := NewRegistry()
+	for _, validator := range []string{"v1", "v2"} {
+		r.Register(validator, 1000)
+		if err := r.Bond(validator, 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, delegator := range []string{"d1", "d2"} {
+		r.Register(delegator, 1000)
+		r.SetDelegator(delegator)
+	}
+	return r
+}
+
+func TestDelegateTargets(t *testing.T) {
+	tests := []struct {
+		name string
+		// redelegate moves 50 from v2 to the target instead of delegating.
+		redelegate bool
+		from, to   string
+		amount     int
+		want       error
+	}{
+		{"delegator to validator", false, "d1", "v1", 50, nil},
+		{"validator to another", false, "v1", "v2", 50, nil},
+		{"validator to itself", false, "v1", "v1", 50, ErrSelfDelegation},
+		{"to a delegator", false, "d1", "d2", 50, ErrNotValidator},
+		{"to an unknown address", false, "d1", "nobody", 50, ErrNotValidator},
+		{"more than the balance", false, "d1", "v1", 1001, ErrInsufficientBalance},
+		{"redelegate", true, "d1", "v1", 50, nil},
+		{"redelegate to itself", true, "v1", "v1", 50, ErrSelfDelegation},
+		{"redelegate more than delegated", true, "d1", "v1", 51, ErrInsufficientDelegation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := delegationRegistry(t)
+			var err error
+			if tt.redelegate {
+				if tt.from != "v2" {
+					if err := r.Delegate(tt.from, "v2", 50); err != nil {
+						t.Fatal(err)
+					}
+				}
+				err = r.Redelegate(tt.from, "v2", tt.to, tt.amount)
+			} else {
+				err = r.Delegate(tt.from, tt.to, tt.amount)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error %v, want %v", err, tt.want)
+			}
+			if err != nil && r.Delegated()[tt.to] != 0 {
+				t.Fatalf("rejected delegation left %d delegated to %s", r.Delegated()[tt.to], tt.to)
+			}
+		})
+	}
+}
+
+func TestRewardSharesAddUp(t *testing.T) {
+	tests := []struct {
+		name        string
+		delegations map[string]int
+		commission  int
+		reward      int
+	}{
+		{"no delegators", nil, 0, 100},
+		{"one delegator", map[string]int{"d1": 100}, 0, 100},
+		{"uneven split", map[string]int{"d1": 33, "d2": 67, "v2": 1}, 0, 101},
+		{"commission", map[string]int{"d1": 300, "d2": 200}, 1500, 97},
+		{"full commission", map[string]int{"d1": 300}, MaxCommission, 50},
+		{"reward below one token each", map[string]int{"d1": 1, "d2": 1, "v2": 1}, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := delegationRegistry(t)
+			for delegator, amount := range tt.delegations {
+				if err := r.Delegate(delegator, "v1", amount); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := r.SetCommission("v1", tt.commission); err != nil {
+				t.Fatal(err)
+			}
+			supply := r.Supply()
+			r.Reward("v1", tt.reward)
+
+			credited := 0
+			for address, ledger := range r.Ledgers() {
+				if ledger.Rewards < 0 {
+					t.Fatalf("%s credited %d", address, ledger.Rewards)
+				}
+				credited += ledger.Rewards
+			}
+			if credited != tt.reward {
+				t.Fatalf("minted %d, credited %d", tt.reward, credited)
+			}
+			if got := r.Supply() - supply; got != tt.reward {
+				t.Fatalf("supply grew by %d, want %d", got, tt.reward)
+			}
+		})
+	}
+}
+
+// TestValidatorLedgers checks that delegators, who come out ahead on their
+// validators' rewards without ever bidding, are not counted as validators.
+func TestValidatorLedgers(t *testing.T) {
+	r := delegationRegistry(t)
+	for _, delegator := range []string{"d1", "d2"} {
+		if err := r.Delegate(delegator, "v1", 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.Reward("v1", 300)
+
+	ledgers := r.ValidatorLedgers()
+	if len(ledgers) != 2 || ledgers["v1"].Rewards != 100 || ledgers["v2"].Rewards != 0 {
+		t.Fatalf("validator ledgers %v, want v1 rewarded 100 and v2 nothing", ledgers)
+	}
+	summary := SummarizeUtility(ledgers)
+	if summary.Validators != 2 || summary.Ahead != 1 {
+		t.Fatalf("%s, want 1 of 2 validators ahead", summary)
+	}
+}
+
+func TestRewardShares(t *testing.T) {
+	tests := []struct {
+		name        string
+		delegations map[string]int
+		// elsewhere is what d2 delegates to v2, which does not win.
+		elsewhere  int
+		commission int
+		reward     int
+		want       map[string]int
+	}{
+		{"half delegated", map[string]int{"d1": 100}, 0, 0, 100, map[string]int{"v1": 50, "d1": 50}},
+		{"commission", map[string]int{"d1": 300, "d2": 200}, 0, 1500, 120, map[string]int{"v1": 35, "d1": 51, "d2": 34}},
+		{"full commission", map[string]int{"d1": 300}, 0, MaxCommission, 50, map[string]int{"v1": 50}},
+		{"remainder to the first address", map[string]int{"d1": 50, "d2": 50}, 0, 0, 3, map[string]int{"v1": 2, "d1": 1}},
+		{"stake with another validator", map[string]int{"d1": 100}, 100, 0, 100, map[string]int{"v1": 50, "d1": 50}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := delegationRegistry(t)
+			for delegator, amount := range tt.delegations {
+				if err := r.Delegate(delegator, "v1", amount); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.elsewhere > 0 {
+				if err := r.Delegate("d2", "v2", tt.elsewhere); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := r.SetCommission("v1", tt.commission); err != nil {
+				t.Fatal(err)
+			}
+			r.Reward("v1", tt.reward)
+			for address, ledger := range r.Ledgers() {
+				if ledger.Rewards != tt.want[address] {
+					t.Fatalf("%s credited %d, want %d", address, ledger.Rewards, tt.want[address])
+				}
+			}
+		})
+	}
+}
+
+func TestUndelegate(t *testing.T) {
+	r := delegationRegistry(t)
+	if err := r.Delegate("d1", "v1", 300); err != nil {
+		t.Fatal(err)
+	}
+	supply := r.Supply()
+
+	steps := []struct {
+		name      string
+		validator string
+		amount    int
+		release   int
+		want      error
+		// delegated, unbonding and balance of d1 afterwards.
+		delegated, unbonding, balance int
+	}{
+		{"part", "v1", 100, 5, nil, 200, 100, 700},
+		{"more than is left", "v1", 201, 6, ErrInsufficientDelegation, 200, 100, 700},
+		{"from another validator", "v2", 1, 6, ErrInsufficientDelegation, 200, 100, 700},
+		{"the rest", "v1", 200, 7, nil, 0, 300, 700},
+	}
+	for _, step := range steps {
+		if err := r.Undelegate("d1", step.validator, step.amount, step.release); !errors.Is(err, step.want) {
+			t.Fatalf("%s: error %v, want %v", step.name, err, step.want)
+		}
+		_, unbonding := r.Stake("d1")
+		balance, _ := r.Balance("d1")
+		if delegated := r.Delegations("d1"); delegated != step.delegated || unbonding != step.unbonding || balance != step.balance {
+			t.Fatalf("%s: delegated %d, unbonding %d, balance %d; want %d, %d, %d", step.name, delegated, unbonding, balance, step.delegated, step.unbonding, step.balance)
+		}
+		if r.Supply() != supply {
+			t.Fatalf("%s: supply %d, want %d", step.name, r.Supply(), supply)
+		}
+	}
+	if delegated := r.Delegated()["v1"]; delegated != 0 {
+		t.Fatalf("%d still delegated to v1", delegated)
+	}
+
+	releases := []struct {
+		round, balance, unbonding int
+	}{
+		{4, 700, 300},
+		{5, 800, 200},
+		{7, 1000, 0},
+	}
+	for _, release := range releases {
+		r.ReleaseUnbonded(release.round)
+		_, unbonding := r.Stake("d1")
+		balance, _ := r.Balance("d1")
+		if balance != release.balance || unbonding != release.unbonding {
+			t.Fatalf("round %d: balance %d and unbonding %d, want %d and %d", release.round, balance, unbonding, release.balance, release.unbonding)
+		}
+	}
+	if r.Supply() != supply {
+		t.Fatalf("supply %d after release, want %d", r.Supply(), supply)
+	}
+}
+
+// TestUndelegateRequest checks that an Undelegate request through the round
+// manager unbonds for UnbondingRounds settled rounds.
+func TestUndelegateRequest(t *testing.T) {
+	economics := Economics{Bonding: Bonding{Enabled: true, UnbondingRounds: 2}}
+	rounds, registry, key, address := testRounds(t, NewVickreyLottery(), BidRules{}, economics)
+	registry.Register("v2", 1000)
+	if err := registry.Bond("v2", 100); err != nil {
+		t.Fatal(err)
+	}
+	for i, req := range []BidRequest{
+		{Kind: Delegate, Validator: "v2", Bid: 300},
+		{Kind: Undelegate, Validator: "v2", Bid: 100},
+	} {
+		if _, err := rounds.SubmitBid(signed(key, address, 0, uint64(i)+1, req)); err != nil {
+			t.Fatalf("%v: %v", req.Kind, err)
+		}
+	}
+	for round, want := range []int{700, 700, 800} {
+		rounds.Settle()
+		if balance, _ := registry.Balance(address); balance != want {
+			t.Fatalf("balance %d after round %d settled, want %d", balance, round+1, want)
+		}
+		rounds.Open()
+	}
+}
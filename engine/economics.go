@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PriceSplit divides what a round collects between burning it,
+// redistributing it to the round's losing bidders pro rata to their bids and
+// paying it into the treasury. The fractions add up to 1.
+type PriceSplit struct {
+	Burn         float64
+	Redistribute float64
+	Treasury     float64
+}
+
+// BurnAll is the split of the original servers: payments leave circulation.
+var BurnAll = PriceSplit{Burn: 1}
+
+// ParsePriceSplit parses "BURN:REDISTRIBUTE:TREASURY", e.g. "0.05:0.5:0.45".
+func ParsePriceSplit(spec string) (PriceSplit, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) != 3 {
+		return PriceSplit{}, fmt.Errorf("price split %q is not BURN:REDISTRIBUTE:TREASURY", spec)
+	}
+	var fractions [3]float64
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(part, 64)
+		if err != nil || f < 0 || f > 1 {
+			return PriceSplit{}, fmt.Errorf("price split %q: %q is not a fraction between 0 and 1", spec, part)
+		}
+		fractions[i] = f
+	}
+	split := PriceSplit{Burn: fractions[0], Redistribute: fractions[1], Treasury: fractions[2]}
+	if sum := split.Burn + split.Redistribute + split.Treasury; math.Abs(sum-1) > 1e-9 {
+		return PriceSplit{}, fmt.Errorf("price split %q adds up to %v, not 1", spec, sum)
+	}
+	return split, nil
+}
+
+func (p PriceSplit) String() string {
+	return fmt.Sprintf("burn %v, redistribute %v, treasury %v", p.Burn, p.Redistribute, p.Treasury)
+}
+
+// Economics decides where the tokens a round collects go, what the winner of
+// a block earns, whether stake is bonded and how misbehaviour is punished.
+type Economics struct {
+	Split    PriceSplit
+	Reward   RewardPolicy
+	Bonding  Bonding
+	Slashing SlashingPolicy
+}
+
+// Distribution is how a round's revenue and reward were paid out.
+type Distribution struct {
+	Burned        int
+	Redistributed int
+	Treasury      int
+	Minted        int
+}
+
+// distribute pays out revenue collected from the round's bids and mints
+// reward to the winning committee, if any, in equal shares. Rounding dust,
+// and the redistributed share when there are no losers, is burned.
+func (e Economics) distribute(registry *Registry, bids []BidItem, committee []string, revenue, reward int) Distribution {
+	d := Distribution{}
+	if revenue > 0 {
+		share := int(float64(revenue) * e.Split.Redistribute)
+		d.Treasury = int(float64(revenue) * e.Split.Treasury)
+		for addr, amount := range proRata(share, bids, memberSet(committee)) {
+			registry.Credit(addr, amount)
+			d.Redistributed += amount
+		}
+		registry.AddTreasury(d.Treasury)
+		d.Burned = revenue - d.Redistributed - d.Treasury
+	}
+	for i, amount := range splitEvenly(reward, len(committee)) {
+		registry.Reward(committee[i], amount)
+		d.Minted += amount
+	}
+	return d
+}
+
+// proRata divides amount between every bidder outside the committee in
+// proportion to their bids. Tokens left over after rounding down go one each
+// to the largest remainders, earliest bid first.
+func proRata(amount int, bids []BidItem, committee map[string]bool) map[string]int {
+	losing := 0
+	for _, bidItem := range bids {
+		if !committee[bidItem.NodeAddress] {
+			losing += bidItem.Bid
+		}
+	}
+	if amount <= 0 || losing == 0 {
+		return nil
+	}
+	type part struct {
+		address   string
+		remainder int
+	}
+	shares := make(map[string]int)
+	parts := make([]part, 0, len(bids))
+	left := amount
+	for _, bidItem := range bids {
+		if committee[bidItem.NodeAddress] {
+			continue
+		}
+		shares[bidItem.NodeAddress] += amount * bidItem.Bid / losing
+		left -= amount * bidItem.Bid / losing
+		parts = append(parts, part{bidItem.NodeAddress, amount * bidItem.Bid % losing})
+	}
+	sort.SliceStable(parts, func(i, j int) bool {
+		return parts[i].remainder > parts[j].remainder
+	})
+	for i := 0; i < left; i++ {
+		shares[parts[i].address]++
+	}
+	return shares
+}
@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePriceSplit(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    PriceSplit
+		wantErr bool
+	}{
+		{"1:0:0", BurnAll, false},
+		{"0.05:0.5:0.45", PriceSplit{Burn: 0.05, Redistribute: 0.5, Treasury: 0.45}, false},
+		{"0.1:0.2:0.7", PriceSplit{Burn: 0.1, Redistribute: 0.2, Treasury: 0.7}, false},
+		{"1:0", PriceSplit{}, true},
+		{"0.5:0.5:0.5", PriceSplit{}, true},
+		{"-0.5:1:0.5", PriceSplit{}, true},
+		{"2:-1:0", PriceSplit{}, true},
+		{"half:0.5:0", PriceSplit{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			split, err := ParsePriceSplit(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if split != tt.want {
+				t.Fatalf("parsed %v, want %v", split, tt.want)
+			}
+		})
+	}
+}
+
+func TestProRata(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    int
+		bids      []BidItem
+		committee []string
+		want      map[string]int
+	}{
+		{"exact shares", 60, []BidItem{{"a", 30}, {"b", 20}, {"c", 10}}, nil, map[string]int{"a": 30, "b": 20, "c": 10}},
+		{"committee is left out", 10, []BidItem{{"a", 30}, {"b", 20}, {"c", 10}}, []string{"a"}, map[string]int{"b": 7, "c": 3}},
+		{"remainder to the largest remainder", 50, []BidItem{{"a", 30}, {"b", 20}, {"c", 10}}, []string{"a"}, map[string]int{"b": 33, "c": 17}},
+		{"equal remainders go to the earliest bid", 100, []BidItem{{"a", 1}, {"b", 1}, {"c", 1}}, nil, map[string]int{"a": 34, "b": 33, "c": 33}},
+		{"several leftover tokens", 5, []BidItem{{"a", 1}, {"b", 1}, {"c", 1}}, nil, map[string]int{"a": 2, "b": 2, "c": 1}},
+		{"zero bid gets nothing", 5, []BidItem{{"a", 0}, {"b", 10}}, nil, map[string]int{"a": 0, "b": 5}},
+		{"nothing to divide", 0, []BidItem{{"a", 10}}, nil, nil},
+		{"no losers", 10, []BidItem{{"a", 10}, {"b", 5}}, []string{"a", "b"}, nil},
+		{"no bids", 10, nil, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := proRata(tt.amount, tt.bids, memberSet(tt.committee))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("proRata(%d) = %v, want %v", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistribute(t *testing.T) {
+	abc := []BidItem{{"a", 30}, {"b", 20}, {"c", 10}}
+	tests := []struct {
+		name      string
+		split     PriceSplit
+		committee []string
+		revenue   int
+		reward    int
+		want      Distribution
+		// redistributed and rewards are what each ledger was credited.
+		redistributed map[string]int
+		rewards       map[string]int
+	}{
+		{"burn everything", BurnAll, []string{"a"}, 20, 0,
+			Distribution{Burned: 20}, nil, nil},
+		{"three-way split", PriceSplit{Burn: 0.05, Redistribute: 0.5, Treasury: 0.45}, []string{"a"}, 100, 10,
+			Distribution{Burned: 5, Redistributed: 50, Treasury: 45, Minted: 10},
+			map[string]int{"b": 33, "c": 17}, map[string]int{"a": 10}},
+		{"rounding dust is burned", PriceSplit{Redistribute: 0.5, Treasury: 0.5}, []string{"a"}, 7, 0,
+			Distribution{Burned: 1, Redistributed: 3, Treasury: 3},
+			map[string]int{"b": 2, "c": 1}, nil},
+		{"no losers burns the redistributed share", PriceSplit{Redistribute: 1}, []string{"a", "b", "c"}, 10, 0,
+			Distribution{Burned: 10}, nil, nil},
+		{"reward split across the committee", BurnAll, []string{"b", "a", "c"}, 0, 10,
+			Distribution{Minted: 10}, nil, map[string]int{"b": 4, "a": 3, "c": 3}},
+		{"nothing collected or minted", BurnAll, nil, 0, 0, Distribution{}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry()
+			for _, bidItem := range abc {
+				registry.Register(bidItem.NodeAddress, 1000)
+			}
+			d := Economics{Split: tt.split}.distribute(registry, abc, tt.committee, tt.revenue, tt.reward)
+			if d != tt.want {
+				t.Fatalf("distributed %+v, want %+v", d, tt.want)
+			}
+			if d.Burned+d.Redistributed+d.Treasury != tt.revenue {
+				t.Fatalf("%+v does not account for revenue %d", d, tt.revenue)
+			}
+			if registry.Treasury() != tt.want.Treasury {
+				t.Fatalf("treasury %d, want %d", registry.Treasury(), tt.want.Treasury)
+			}
+			for address, ledger := range registry.Ledgers() {
+				if ledger.Redistributed != tt.redistributed[address] || ledger.Rewards != tt.rewards[address] {
+					t.Fatalf("%s credited %d and rewarded %d, want %d and %d", address,
+						ledger.Redistributed, ledger.Rewards, tt.redistributed[address], tt.rewards[address])
+				}
+			}
+		})
+	}
+}
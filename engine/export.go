@@ -0,0 +1,229 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tealeg/xlsx"
+)
+
+// RunInfo identifies the run an export belongs to. Replaying the same bids
+// with the same mechanism and seed reproduces the chain block for block.
+type RunInfo struct {
+	Mechanism string
+	Seed      int64
+	Committee string
+	Weighting string
+	Reserve   string
+	Economics Economics
+}
+
+// ExportBlockchainToExcel writes the blocks to filename as a workbook with a
+// "Run" sheet describing the run, a "Blockchain" sheet, a "Ledger" sheet of
+// what every validator earned and paid, an "Evidence" sheet of the offences
+// recorded in the blocks and a "Sortition" sheet of every sortition ticket.
+func ExportBlockchainToExcel(blocks []Block, info RunInfo, ledgers map[string]Ledger, filename string) error {
+	file := xlsx.NewFile()
+	runSheet, err := file.AddSheet("Run")
+	if err != nil {
+		return fmt.Errorf("cannot add sheet: %w", err)
+	}
+
+	row := runSheet.AddRow()
+	row.AddCell().Value = "Mechanism"
+	row.AddCell().Value = info.Mechanism
+	row = runSheet.AddRow()
+	row.AddCell().Value = "Seed"
+	row.AddCell().Value = strconv.FormatInt(info.Seed, 10)
+	row = runSheet.AddRow()
+	row.AddCell().Value = "Committee"
+	row.AddCell().Value = info.Committee
+	row = runSheet.AddRow()
+	row.AddCell().Value = "Weighting"
+	row.AddCell().Value = info.Weighting
+	row = runSheet.AddRow()
+	row.AddCell().Value = "Reserve"
+	row.AddCell().Value = info.Reserve
+	row = runSheet.AddRow()
+	row.AddCell().Value = "Price Split"
+	row.AddCell().Value = info.Economics.Split.String()
+	row = runSheet.AddRow()
+	row.AddCell().Value = "Reward"
+	row.AddCell().Value = info.Economics.Reward.String()
+	row = runSheet.AddRow()
+	row.AddCell().Value = "Slashing"
+	row.AddCell().Value = info.Economics.Slashing.String()
+
+	sheet, err := file.AddSheet("Blockchain")
+	if err != nil {
+		return fmt.Errorf("cannot add sheet: %w", err)
+	}
+
+	row = sheet.AddRow()
+	row.AddCell().Value = "Index"
+	row.AddCell().Value = "Timestamp"
+	row.AddCell().Value = "BPM"
+	row.AddCell().Value = "Hash"
+	row.AddCell().Value = "PrevHash"
+	row.AddCell().Value = "Validator"
+	row.AddCell().Value = "Committee"
+	row.AddCell().Value = "Proposer"
+	row.AddCell().Value = "Transfer"
+	row.AddCell().Value = "Round"
+	row.AddCell().Value = "Bids Opened"
+	row.AddCell().Value = "Bids Closed"
+	row.AddCell().Value = "Reserve"
+	row.AddCell().Value = "Reward"
+	row.AddCell().Value = "Fees"
+	row.AddCell().Value = "Burned"
+	row.AddCell().Value = "Supply"
+	row.AddCell().Value = "Evidence"
+
+	for _, block := range blocks {
+		row := sheet.AddRow()
+		row.AddCell().Value = strconv.Itoa(block.Index)
+		row.AddCell().Value = block.Timestamp
+		row.AddCell().Value = strconv.Itoa(block.BPM)
+		row.AddCell().Value = block.Hash
+		row.AddCell().Value = block.PrevHash
+		row.AddCell().Value = block.Validator
+		row.AddCell().Value = strings.Join(block.Committee, " ")
+		row.AddCell().Value = block.Proposer
+		row.AddCell().Value = strconv.Itoa(block.Transfer)
+		row.AddCell().Value = strconv.Itoa(block.Round)
+		row.AddCell().Value = block.BidsOpened
+		row.AddCell().Value = block.BidsClosed
+		row.AddCell().Value = strconv.Itoa(block.Reserve)
+		row.AddCell().Value = strconv.Itoa(block.Reward)
+		row.AddCell().Value = strconv.Itoa(block.Fees)
+		row.AddCell().Value = strconv.Itoa(block.Burned)
+		row.AddCell().Value = strconv.Itoa(block.Supply)
+		row.AddCell().Value = strconv.Itoa(len(block.Evidence))
+	}
+
+	ledgerSheet, err := file.AddSheet("Ledger")
+	if err != nil {
+		return fmt.Errorf("cannot add sheet: %w", err)
+	}
+	row = ledgerSheet.AddRow()
+	row.AddCell().Value = "Validator"
+	row.AddCell().Value = "Wins"
+	row.AddCell().Value = "Rewards"
+	row.AddCell().Value = "Redistributed"
+	row.AddCell().Value = "Payments"
+	row.AddCell().Value = "Slashed"
+	row.AddCell().Value = "Net Utility"
+
+	addresses := make([]string, 0, len(ledgers))
+	for address := range ledgers {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		ledger := ledgers[address]
+		row := ledgerSheet.AddRow()
+		row.AddCell().Value = address
+		row.AddCell().Value = strconv.Itoa(ledger.Wins)
+		row.AddCell().Value = strconv.Itoa(ledger.Rewards)
+		row.AddCell().Value = strconv.Itoa(ledger.Redistributed)
+		row.AddCell().Value = strconv.Itoa(ledger.Payments)
+		row.AddCell().Value = strconv.Itoa(ledger.Slashed)
+		row.AddCell().Value = strconv.Itoa(ledger.Net())
+	}
+
+	evidenceSheet, err := file.AddSheet("Evidence")
+	if err != nil {
+		return fmt.Errorf("cannot add sheet: %w", err)
+	}
+	row = evidenceSheet.AddRow()
+	row.AddCell().Value = "Block"
+	row.AddCell().Value = "Round"
+	row.AddCell().Value = "Validator"
+	row.AddCell().Value = "Offence"
+	row.AddCell().Value = "Penalty"
+	row.AddCell().Value = "Slashed"
+	row.AddCell().Value = "Proof"
+	for _, block := range blocks {
+		for _, evidence := range block.Evidence {
+			row := evidenceSheet.AddRow()
+			row.AddCell().Value = strconv.Itoa(block.Index)
+			row.AddCell().Value = strconv.Itoa(evidence.Round)
+			row.AddCell().Value = evidence.Validator
+			row.AddCell().Value = evidence.Offence
+			row.AddCell().Value = evidence.Penalty
+			row.AddCell().Value = strconv.Itoa(evidence.Slashed)
+			row.AddCell().Value = strings.Join(evidence.Proof, " ")
+		}
+	}
+
+	sortitionSheet, err := file.AddSheet("Sortition")
+	if err != nil {
+		return fmt.Errorf("cannot add sheet: %w", err)
+	}
+	row = sortitionSheet.AddRow()
+	row.AddCell().Value = "Block"
+	row.AddCell().Value = "Seed"
+	row.AddCell().Value = "Size"
+	row.AddCell().Value = "Total Stake"
+	row.AddCell().Value = "Validator"
+	row.AddCell().Value = "Public Key"
+	row.AddCell().Value = "Proof"
+	row.AddCell().Value = "Stake"
+	for _, block := range blocks {
+		if block.Sortition == nil {
+			continue
+		}
+		record := block.Sortition
+		for _, ticket := range record.Tickets {
+			row := sortitionSheet.AddRow()
+			row.AddCell().Value = strconv.Itoa(block.Index)
+			row.AddCell().Value = record.Seed
+			row.AddCell().Value = strconv.FormatFloat(record.Size, 'g', -1, 64)
+			row.AddCell().Value = strconv.Itoa(record.TotalStake)
+			row.AddCell().Value = ticket.Validator
+			row.AddCell().Value = ticket.PublicKey
+			row.AddCell().Value = ticket.Proof
+			row.AddCell().Value = strconv.Itoa(ticket.Stake)
+		}
+	}
+
+	if err := file.Save(filename); err != nil {
+		return fmt.Errorf("cannot save file: %w", err)
+	}
+	return nil
+}
+
+// ChainPath returns where the chain is exported next to the workbook at
+// exportPath: the same name with a .jsonl extension.
+func ChainPath(exportPath string) string {
+	return strings.TrimSuffix(exportPath, filepath.Ext(exportPath)) + ".jsonl"
+}
+
+// ExportChain writes the blocks to filename as JSON lines, one block per
+// line, encoded as in the chain message. Unlike the workbook it holds every
+// field of every block, and it is what tools/verify re-checks.
+func ExportChain(blocks []Block, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create chain export: %w", err)
+	}
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, block := range blocks {
+		if err := enc.Encode(block); err != nil {
+			file.Close()
+			return fmt.Errorf("cannot encode block %d: %w", block.Index, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot save chain export: %w", err)
+	}
+	return file.Close()
+}
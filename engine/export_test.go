@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChainPath(t *testing.T) {
+	tests := []struct{ export, want string }{
+		{"blockchain.xlsx", "blockchain.jsonl"},
+		{"runs/sortition.xlsx", "runs/sortition.jsonl"},
+		{"chain", "chain.jsonl"},
+	}
+	for _, tt := range tests {
+		if got := ChainPath(tt.export); got != tt.want {
+			t.Fatalf("ChainPath(%q) = %q, want %q", tt.export, got, tt.want)
+		}
+	}
+}
+
+// TestExportChainRoundTrip checks that a sortition chain read back from its
+// JSON-lines export still hashes and verifies block for block.
+func TestExportChainRoundTrip(t *testing.T) {
+	sim := NewSimulation(Config{Mechanism: NewSortition(2), Seed: 3}, SimConfig{Rounds: 5, Validators: 4, Balance: 1000, BaseCost: 15, MinBPM: 60, MaxBPM: 80, Quiet: true})
+	sim.Run()
+	blocks := sim.Chain().Blocks()
+	path := filepath.Join(t.TempDir(), "chain.jsonl")
+	if err := ExportChain(blocks, path); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	dec := json.NewDecoder(file)
+	var read []Block
+	for dec.More() {
+		var block Block
+		if err := dec.Decode(&block); err != nil {
+			t.Fatal(err)
+		}
+		read = append(read, block)
+	}
+	if len(read) != len(blocks) || len(read) < 2 {
+		t.Fatalf("read %d blocks, exported %d", len(read), len(blocks))
+	}
+	for i, block := range read[1:] {
+		if hash := CalculateBlockHash(block); hash != block.Hash || hash != blocks[i+1].Hash {
+			t.Fatalf("block %d hashes to %s, exported as %s", block.Index, hash, blocks[i+1].Hash)
+		}
+		if block.Sortition == nil {
+			t.Fatalf("block %d lost its sortition record", block.Index)
+		}
+		if err := VerifyBlockSortition(block, read[i].Hash); err != nil {
+			t.Fatalf("block %d: %v", block.Index, err)
+		}
+	}
+}
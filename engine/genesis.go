@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrNotAllocated is returned when a validator without a genesis allocation
+// registers and the server gives unknown registrants nothing.
+var ErrNotAllocated = errors.New("validator has no genesis allocation")
+
+// Allocation grants a starting stake to one validator, identified either by
+// its hex encoded Ed25519 public key or by its address.
+type Allocation struct {
+	PublicKey string `json:"public_key,omitempty"`
+	Address   string `json:"address,omitempty"`
+	Stake     int    `json:"stake"`
+}
+
+// GenesisFile is the allocation file loaded with -genesis:
+//
+//	{"allocations": [
+//	  {"public_key": "3b6a27bc...", "stake": 50000},
+//	  {"address": "9f86d081...", "stake": 1000}
+//	]}
+type GenesisFile struct {
+	Allocations []Allocation `json:"allocations"`
+}
+
+// StakePolicy decides the starting balance of a registering validator. The
+// balance a client declares is never trusted: validators listed in Stakes get
+// their allocation, and everyone else gets the Faucet amount, or is turned
+// away when Faucet is zero.
+type StakePolicy struct {
+	Stakes map[string]int
+	Faucet int
+}
+
+// LoadGenesis reads an allocation file into a policy that hands faucet to
+// validators it does not list.
+func LoadGenesis(path string, faucet int) (StakePolicy, error) {
+	policy := StakePolicy{Stakes: make(map[string]int), Faucet: faucet}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return policy, err
+	}
+	var file GenesisFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return policy, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i, alloc := range file.Allocations {
+		address := alloc.Address
+		if alloc.PublicKey != "" {
+			pub, err := ParsePublicKey(alloc.PublicKey)
+			if err != nil {
+				return policy, fmt.Errorf("%s: allocation %d: %w", path, i, err)
+			}
+			if alloc.Address != "" && alloc.Address != AddressFromKey(pub) {
+				return policy, fmt.Errorf("%s: allocation %d: address does not match public_key", path, i)
+			}
+			address = AddressFromKey(pub)
+		}
+		if address == "" {
+			return policy, fmt.Errorf("%s: allocation %d has neither public_key nor address", path, i)
+		}
+		if alloc.Stake <= 0 {
+			return policy, fmt.Errorf("%s: allocation %d: stake must be positive", path, i)
+		}
+		if _, ok := policy.Stakes[address]; ok {
+			return policy, fmt.Errorf("%s: allocation %d: %s is listed twice", path, i, address)
+		}
+		policy.Stakes[address] = alloc.Stake
+	}
+	return policy, nil
+}
+
+// Stake returns the starting balance of the validator at address.
+func (p StakePolicy) Stake(address string) (int, error) {
+	if stake, ok := p.Stakes[address]; ok {
+		return stake, nil
+	}
+	if p.Faucet <= 0 {
+		return 0, ErrNotAllocated
+	}
+	return p.Faucet, nil
+}
+
+func (p StakePolicy) String() string {
+	faucet := "reject unknown validators"
+	if p.Faucet > 0 {
+		faucet = fmt.Sprintf("faucet %d", p.Faucet)
+	}
+	return fmt.Sprintf("%d genesis allocations, %s", len(p.Stakes), faucet)
+}
@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadGenesis(t *testing.T) {
+	pub := sortitionKey("genesis").Public().(ed25519.PublicKey)
+	key := hex.EncodeToString(pub)
+	address := AddressFromKey(pub)
+	tests := []struct {
+		name string
+		file string
+		want map[string]int
+		// err is a fragment of the error, empty when the file is valid.
+		err string
+	}{
+		{"by key and by address", `{"allocations": [{"public_key": "` + key + `", "stake": 500}, {"address": "abc", "stake": 10}]}`,
+			map[string]int{address: 500, "abc": 10}, ""},
+		{"key with its own address", `{"allocations": [{"public_key": "` + key + `", "address": "` + address + `", "stake": 5}]}`,
+			map[string]int{address: 5}, ""},
+		{"no allocations", `{}`, map[string]int{}, ""},
+		{"not JSON", `allocations: []`, nil, "parse"},
+		{"truncated", `{"allocations": [`, nil, "parse"},
+		{"stake is not a number", `{"allocations": [{"address": "abc", "stake": "10"}]}`, nil, "parse"},
+		{"key is not hex", `{"allocations": [{"public_key": "xyz", "stake": 10}]}`, nil, "allocation 0: public key"},
+		{"key is too short", `{"allocations": [{"public_key": "abcd", "stake": 10}]}`, nil, "want 32 bytes, got 2"},
+		{"key and address disagree", `{"allocations": [{"public_key": "` + key + `", "address": "abc", "stake": 10}]}`, nil, "does not match"},
+		{"neither key nor address", `{"allocations": [{"address": "abc", "stake": 10}, {"stake": 10}]}`, nil, "allocation 1 has neither"},
+		{"zero stake", `{"allocations": [{"address": "abc"}]}`, nil, "stake must be positive"},
+		{"negative stake", `{"allocations": [{"address": "abc", "stake": -10}]}`, nil, "stake must be positive"},
+		{"listed twice", `{"allocations": [{"address": "abc", "stake": 10}, {"address": "abc", "stake": 20}]}`, nil, "listed twice"},
+		{"listed by key and by address", `{"allocations": [{"public_key": "` + key + `", "stake": 10}, {"address": "` + address + `", "stake": 20}]}`, nil, "listed twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "genesis.json")
+			if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			policy, err := LoadGenesis(path, 100)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want one mentioning %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(policy.Stakes) != len(tt.want) {
+				t.Fatalf("stakes %v, want %v", policy.Stakes, tt.want)
+			}
+			for address, stake := range tt.want {
+				if policy.Stakes[address] != stake {
+					t.Fatalf("stakes %v, want %v", policy.Stakes, tt.want)
+				}
+			}
+		})
+	}
+
+	if _, err := LoadGenesis(filepath.Join(t.TempDir(), "missing.json"), 100); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("missing file: error %v, want one wrapping os.ErrNotExist", err)
+	}
+}
+
+func TestStakePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  StakePolicy
+		address string
+		want    int
+		err     error
+	}{
+		{"allocated", StakePolicy{Stakes: map[string]int{"abc": 500}, Faucet: 100}, "abc", 500, nil},
+		{"faucet", StakePolicy{Stakes: map[string]int{"abc": 500}, Faucet: 100}, "def", 100, nil},
+		{"allocated without a faucet", StakePolicy{Stakes: map[string]int{"abc": 500}}, "abc", 500, nil},
+		{"not allocated", StakePolicy{Stakes: map[string]int{"abc": 500}}, "def", 0, ErrNotAllocated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stake, err := tt.policy.Stake(tt.address)
+			if stake != tt.want || !errors.Is(err, tt.err) {
+				t.Fatalf("Stake(%q) = %d, %v, want %d, %v", tt.address, stake, err, tt.want, tt.err)
+			}
+		})
+	}
+}
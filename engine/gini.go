@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"math"
+	"sort"
+)
+
+// GiniFunc computes the Gini coefficient of a set of balances.
+type GiniFunc func(incomes []int) float64
+
+// ExactGini computes the Gini coefficient from every pairwise difference.
+// It is O(n^2) and matches the metric used by the _gen variants.
+func ExactGini(incomes []int) float64 {
+	n := float64(len(incomes))
+	if n == 0 {
+		return 0
+	}
+
+	mean := 0.0
+	sumOfAbsoluteDifferences := 0.0
+
+	for _, income := range incomes {
+		mean += float64(income)
+		for _, otherIncome := range incomes {
+			sumOfAbsoluteDifferences += math.Abs(float64(income - otherIncome))
+		}
+	}
+
+	mean /= n
+	if mean == 0 {
+		return 0
+	}
+
+	return sumOfAbsoluteDifferences / (2 * n * n * mean)
+}
+
+// SortedGini computes the Gini coefficient from the rank-weighted sum of the
+// sorted balances. It is O(n log n) and used by the Vick and Random variants.
+func SortedGini(incomes []int) float64 {
+	if len(incomes) == 0 {
+		return 0
+	}
+
+	sorted := append([]int(nil), incomes...)
+	sort.Ints(sorted)
+	var sumOfAbsoluteDifferences float64 = 0
+	subSum := 0
+	for i, income := range sorted {
+		sumOfAbsoluteDifferences += float64(income * (2*i - len(sorted) + 1))
+		subSum += income
+	}
+	if subSum == 0 {
+		return 0
+	}
+
+	return sumOfAbsoluteDifferences / float64(subSum*len(sorted))
+}
@@ -0,0 +1,227 @@
+package engine
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var (
+	// ErrBadSignature is returned when a bid's signature does not verify
+	// against the validator's registered key.
+	ErrBadSignature = errors.New("bid signature does not verify")
+	// ErrBadResume is returned when a resuming validator does not answer
+	// the connection's challenge with its registered key.
+	ErrBadResume = errors.New("resume signature does not verify")
+	// ErrStaleNonce is returned when a signed bid reuses a nonce.
+	ErrStaleNonce = errors.New("bid nonce is not greater than the last one")
+	// ErrWrongRound is returned when a bid is signed for another round.
+	ErrWrongRound = errors.New("bid is for a different round")
+	// ErrUnknownValidator is returned for bids from unregistered addresses.
+	ErrUnknownValidator = errors.New("unknown validator")
+	// ErrAlreadyRegistered is returned when a public key registers twice.
+	ErrAlreadyRegistered = errors.New("validator is already registered")
+	// ErrEvicted is returned when a key registers again after its account
+	// was evicted, which would grant it a second starting stake.
+	ErrEvicted = errors.New("validator was evicted")
+	// ErrSignatureRequired is returned when the server only accepts
+	// validators with a public key.
+	ErrSignatureRequired = errors.New("server requires signed registrations")
+)
+
+// BidKind distinguishes plain bids from the two steps of a sealed bid.
+type BidKind int
+
+const (
+	// PlainBid states its amount openly.
+	PlainBid BidKind = iota
+	// CommitBid carries only Commitment while the round is open.
+	CommitBid
+	// RevealBid opens an earlier commitment with Bid and Salt.
+	RevealBid
+	// BondStake and UnbondStake are not bids: they move Bid tokens into and
+	// out of the validator's bonded stake, under the same signature and nonce
+	// rules. They are accepted whether or not a round is open.
+	BondStake
+	UnbondStake
+	// Delegate, Undelegate and Redelegate move Bid tokens of a delegator's
+	// stake to, from and between validators. SetCommission sets a
+	// validator's commission to Bid basis points. Like BondStake they are
+	// accepted whether or not a round is open.
+	Delegate
+	Undelegate
+	Redelegate
+	SetCommission
+)
+
+// staking reports whether requests of kind move stake rather than bid.
+func (k BidKind) staking() bool {
+	return k >= BondStake
+}
+
+// BidRequest is a bid as submitted by a validator. Round, Nonce and
+// Signature are only checked for validators that registered a public key.
+// Commitment is set for a CommitBid, and Salt for a RevealBid. Validator is
+// the target of a delegation, and From the validator a Redelegate moves
+// stake away from. Proof is the bid's sortition proof in rounds run by
+// Sortition; it is signed by itself and so not part of the payload.
+type BidRequest struct {
+	Address    string
+	Kind       BidKind
+	Round      int
+	Nonce      uint64
+	BPM        int
+	Bid        int
+	Commitment []byte
+	Salt       []byte
+	Validator  string
+	From       string
+	Proof      []byte
+	Signature  []byte
+}
+
+// AddressFromKey derives a validator address from its Ed25519 public key.
+func AddressFromKey(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:])
+}
+
+// ParsePublicKey decodes a hex encoded Ed25519 public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("public key: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key: want %d bytes, got %d", ed25519.PublicKeySize, len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// BidPayload is the exact byte string a validator signs for a bid. Binding
+// the address, round and nonce stops a bid from being replayed by anyone,
+// in another round, or twice.
+func BidPayload(address string, round int, nonce uint64, bpm, bid int) []byte {
+	return []byte("bid|" + address + "|" + strconv.Itoa(round) + "|" +
+		strconv.FormatUint(nonce, 10) + "|" + strconv.Itoa(bpm) + "|" + strconv.Itoa(bid))
+}
+
+// CommitPayload is what a validator signs to commit to a sealed bid.
+func CommitPayload(address string, round int, nonce uint64, bpm int, commitment []byte) []byte {
+	return []byte("commit|" + address + "|" + strconv.Itoa(round) + "|" +
+		strconv.FormatUint(nonce, 10) + "|" + strconv.Itoa(bpm) + "|" + hex.EncodeToString(commitment))
+}
+
+// RevealPayload is what a validator signs to open its commitment.
+func RevealPayload(address string, round int, nonce uint64, bid int, salt []byte) []byte {
+	return []byte("reveal|" + address + "|" + strconv.Itoa(round) + "|" +
+		strconv.FormatUint(nonce, 10) + "|" + strconv.Itoa(bid) + "|" + hex.EncodeToString(salt))
+}
+
+// BondPayload is what a validator signs to bond amount of its balance.
+func BondPayload(address string, nonce uint64, amount int) []byte {
+	return []byte("bond|" + address + "|" + strconv.FormatUint(nonce, 10) + "|" + strconv.Itoa(amount))
+}
+
+// UnbondPayload is what a validator signs to start unbonding amount.
+func UnbondPayload(address string, nonce uint64, amount int) []byte {
+	return []byte("unbond|" + address + "|" + strconv.FormatUint(nonce, 10) + "|" + strconv.Itoa(amount))
+}
+
+// DelegatePayload is what a delegator signs to delegate amount to validator.
+func DelegatePayload(address string, nonce uint64, validator string, amount int) []byte {
+	return []byte("delegate|" + address + "|" + strconv.FormatUint(nonce, 10) + "|" + validator + "|" + strconv.Itoa(amount))
+}
+
+// UndelegatePayload is what a delegator signs to start unbonding amount of
+// its delegation to validator.
+func UndelegatePayload(address string, nonce uint64, validator string, amount int) []byte {
+	return []byte("undelegate|" + address + "|" + strconv.FormatUint(nonce, 10) + "|" + validator + "|" + strconv.Itoa(amount))
+}
+
+// RedelegatePayload is what a delegator signs to move amount of its
+// delegation from one validator to another.
+func RedelegatePayload(address string, nonce uint64, from, to string, amount int) []byte {
+	return []byte("redelegate|" + address + "|" + strconv.FormatUint(nonce, 10) + "|" + from + "|" + to + "|" + strconv.Itoa(amount))
+}
+
+// CommissionPayload is what a validator signs to set its commission.
+func CommissionPayload(address string, nonce uint64, commission int) []byte {
+	return []byte("commission|" + address + "|" + strconv.FormatUint(nonce, 10) + "|" + strconv.Itoa(commission))
+}
+
+// payload returns the bytes signed for req according to its kind.
+func (req BidRequest) payload() []byte {
+	switch req.Kind {
+	case CommitBid:
+		return CommitPayload(req.Address, req.Round, req.Nonce, req.BPM, req.Commitment)
+	case RevealBid:
+		return RevealPayload(req.Address, req.Round, req.Nonce, req.Bid, req.Salt)
+	case BondStake:
+		return BondPayload(req.Address, req.Nonce, req.Bid)
+	case UnbondStake:
+		return UnbondPayload(req.Address, req.Nonce, req.Bid)
+	case Delegate:
+		return DelegatePayload(req.Address, req.Nonce, req.Validator, req.Bid)
+	case Undelegate:
+		return UndelegatePayload(req.Address, req.Nonce, req.Validator, req.Bid)
+	case Redelegate:
+		return RedelegatePayload(req.Address, req.Nonce, req.From, req.Validator, req.Bid)
+	case SetCommission:
+		return CommissionPayload(req.Address, req.Nonce, req.Bid)
+	default:
+		return BidPayload(req.Address, req.Round, req.Nonce, req.BPM, req.Bid)
+	}
+}
+
+// Commitment returns H(address || round || bid || salt), the SHA-256 digest
+// of the bidder's address and "|", then the round and the bid as 8-byte
+// big-endian integers, then the salt. Binding the address and round stops a
+// validator from copying another's commitment, or its own from an earlier
+// round, and revealing it once the original is opened.
+func Commitment(address string, round, bid int, salt []byte) []byte {
+	buf := make([]byte, 0, len(address)+1+16+len(salt))
+	buf = append(buf, address...)
+	buf = append(buf, '|')
+	buf = binary.BigEndian.AppendUint64(buf, uint64(round))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(bid))
+	sum := sha256.Sum256(append(buf, salt...))
+	return sum[:]
+}
+
+// SignBid signs a bid request in place with the validator's private key.
+func SignBid(priv ed25519.PrivateKey, req *BidRequest) {
+	req.Signature = ed25519.Sign(priv, req.payload())
+}
+
+// ResumePayload is what a reconnecting validator signs to prove it holds the
+// key of an existing account. The challenge is fresh per connection, so a
+// captured resume cannot be replayed.
+func ResumePayload(challenge string) []byte {
+	return []byte("resume|" + challenge)
+}
+
+// SignResume answers a server's resume challenge.
+func SignResume(priv ed25519.PrivateKey, challenge string) []byte {
+	return ed25519.Sign(priv, ResumePayload(challenge))
+}
+
+// VerifyResume reports whether sig answers challenge for pub.
+func VerifyResume(pub ed25519.PublicKey, challenge string, sig []byte) bool {
+	if len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(pub, ResumePayload(challenge), sig)
+}
+
+// VerifyBid reports whether req carries a valid signature by pub.
+func VerifyBid(pub ed25519.PublicKey, req BidRequest) bool {
+	if len(req.Signature) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(pub, req.payload(), req.Signature)
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+)
+
+// testRounds returns a round manager on a virtual clock, with an open round,
+// and a keyed validator holding 1000 tokens.
+func testRounds(t *testing.T, mechanism SelectionMechanism, rules BidRules, economics Economics) (*RoundManager, *Registry, ed25519.PrivateKey, string) {
+	t.Helper()
+	clock := NewVirtualClock(simEpoch)
+	registry := NewRegistry()
+	key := sortitionKey("validator")
+	address, err := registry.RegisterKey(key.Public().(ed25519.PublicKey), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rounds := NewRoundManager(NewChain(clock.Now()), registry, mechanism, clock, rules, economics, 1)
+	rounds.Open()
+	return rounds, registry, key, address
+}
+
+// signed returns req from address, signed by key for round with nonce.
+func signed(key ed25519.PrivateKey, address string, round int, nonce uint64, req BidRequest) BidRequest {
+	req.Address, req.Round, req.Nonce = address, round, nonce
+	SignBid(key, &req)
+	return req
+}
+
+func TestAuthorize(t *testing.T) {
+	key := sortitionKey("validator")
+	other := sortitionKey("other")
+	address := AddressFromKey(key.Public().(ed25519.PublicKey))
+	bid := BidRequest{BPM: 70, Bid: 10}
+	tampered := signed(key, address, 1, 2, bid)
+	tampered.Bid = 1000
+	unsigned := bid
+	unsigned.Address, unsigned.Round, unsigned.Nonce = address, 1, 2
+	forged := signed(other, address, 1, 2, bid)
+
+	tests := []struct {
+		name string
+		req  BidRequest
+		want error
+		// nonce is the last accepted nonce afterwards.
+		nonce uint64
+	}{
+		{"tampered bid", tampered, ErrBadSignature, 1},
+		{"unsigned", unsigned, ErrBadSignature, 1},
+		{"signed by another key", forged, ErrBadSignature, 1},
+		{"replayed nonce", signed(key, address, 1, 1, bid), ErrStaleNonce, 1},
+		{"older nonce", signed(key, address, 1, 0, bid), ErrStaleNonce, 1},
+		{"replayed across kinds", signed(key, address, 0, 1, BidRequest{Kind: BondStake, Bid: 10}), ErrStaleNonce, 1},
+		{"next nonce", signed(key, address, 1, 2, bid), nil, 2},
+		{"nonces may skip", signed(key, address, 1, 10, bid), nil, 10},
+		{"unknown address", signed(key, "nobody", 1, 2, bid), ErrUnknownValidator, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			if _, err := r.RegisterKey(key.Public().(ed25519.PublicKey), 1000); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Authorize(signed(key, address, 1, 1, bid)); err != nil {
+				t.Fatalf("first bid: %v", err)
+			}
+			if err := r.Authorize(tt.req); !errors.Is(err, tt.want) {
+				t.Fatalf("error %v, want %v", err, tt.want)
+			}
+			if got := r.Nonce(address); got != tt.nonce {
+				t.Fatalf("nonce %d, want %d", got, tt.nonce)
+			}
+		})
+	}
+}
+
+func TestAuthorizeKeyless(t *testing.T) {
+	r := NewRegistry()
+	r.Register("legacy", 1000)
+	for i := 0; i < 2; i++ {
+		if err := r.Authorize(BidRequest{Address: "legacy", BPM: 70, Bid: 10}); err != nil {
+			t.Fatalf("keyless bid %d: %v", i, err)
+		}
+	}
+}
+
+func TestSubmitBidReplay(t *testing.T) {
+	rounds, registry, key, address := testRounds(t, NewVickreyLottery(), BidRules{}, Economics{})
+	bid := signed(key, address, 1, 1, BidRequest{BPM: 70, Bid: 10})
+	if _, err := rounds.SubmitBid(bid); err != nil {
+		t.Fatal(err)
+	}
+	rounds.Settle()
+	rounds.Open()
+
+	tests := []struct {
+		name string
+		req  BidRequest
+		want error
+	}{
+		{"replayed in the next round", bid, ErrWrongRound},
+		{"re-signed for the next round with the old nonce", signed(key, address, 2, 1, BidRequest{BPM: 70, Bid: 10}), ErrStaleNonce},
+		{"signed for a future round", signed(key, address, 3, 2, BidRequest{BPM: 70, Bid: 10}), ErrWrongRound},
+		{"next round, next nonce", signed(key, address, 2, 2, BidRequest{BPM: 70, Bid: 10}), nil},
+	}
+	for _, tt := range tests {
+		balance, _ := registry.Balance(address)
+		if _, err := rounds.SubmitBid(tt.req); !errors.Is(err, tt.want) {
+			t.Fatalf("%s: error %v, want %v", tt.name, err, tt.want)
+		}
+		after, _ := registry.Balance(address)
+		if tt.want != nil && after != balance {
+			t.Fatalf("%s: rejected bid moved the balance from %d to %d", tt.name, balance, after)
+		}
+	}
+}
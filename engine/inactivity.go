@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// InactivityMode is what happens to the account of a validator that stays
+// disconnected.
+type InactivityMode int
+
+const (
+	// KeepInactive leaves disconnected accounts untouched; their balances
+	// keep counting toward the Gini coefficient.
+	KeepInactive InactivityMode = iota
+	// FreezeInactive excludes the account from the fairness metrics until
+	// the validator resumes.
+	FreezeInactive
+	// EvictInactive deletes the account and its balance. The key cannot
+	// register again.
+	EvictInactive
+)
+
+// InactivityPolicy applies Mode to accounts that have been disconnected for
+// at least After settled rounds.
+type InactivityPolicy struct {
+	Mode  InactivityMode
+	After int
+}
+
+// ParseInactivityPolicy parses "keep", "freeze:N" or "evict:N".
+func ParseInactivityPolicy(spec string) (InactivityPolicy, error) {
+	name, after, hasAfter := strings.Cut(spec, ":")
+	policy := InactivityPolicy{}
+	switch name {
+	case "keep":
+		return policy, nil
+	case "freeze":
+		policy.Mode = FreezeInactive
+	case "evict":
+		policy.Mode = EvictInactive
+	default:
+		return policy, fmt.Errorf("unknown inactivity policy %q (available: keep, freeze:N, evict:N)", spec)
+	}
+	if !hasAfter {
+		return policy, fmt.Errorf("inactivity policy %q needs a round count, e.g. %s:10", spec, name)
+	}
+	n, err := strconv.Atoi(after)
+	if err != nil || n < 0 {
+		return policy, fmt.Errorf("inactivity policy %q: invalid round count", spec)
+	}
+	policy.After = n
+	return policy, nil
+}
+
+func (p InactivityPolicy) String() string {
+	switch p.Mode {
+	case FreezeInactive:
+		return "freeze:" + strconv.Itoa(p.After)
+	case EvictInactive:
+		return "evict:" + strconv.Itoa(p.After)
+	default:
+		return "keep"
+	}
+}
+
+// ApplyInactivity is called after round settles. Connected validators are
+// marked as seen in that round; accounts disconnected for policy.After rounds
+// or more are frozen or evicted. It returns the addresses newly affected.
+func (r *Registry) ApplyInactivity(round int, policy InactivityPolicy) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var affected []string
+	for address, node := range r.nodes {
+		if node.Connected {
+			node.LastSeen = round
+			continue
+		}
+		if policy.Mode == KeepInactive || round-node.LastSeen < policy.After || node.Bid > 0 {
+			continue
+		}
+		switch policy.Mode {
+		case FreezeInactive:
+			if !node.Frozen {
+				node.Frozen = true
+				affected = append(affected, address)
+			}
+		case EvictInactive:
+			delete(r.nodes, address)
+			r.evicted[address] = true
+			affected = append(affected, address)
+		}
+	}
+	return affected
+}
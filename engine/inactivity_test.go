@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+)
+
+func TestEvictedKeyCannotRegisterAgain(t *testing.T) {
+	tests := []struct {
+		policy InactivityPolicy
+		want   error
+	}{
+		{InactivityPolicy{Mode: EvictInactive, After: 2}, ErrEvicted},
+		{InactivityPolicy{Mode: FreezeInactive, After: 2}, ErrAlreadyRegistered},
+		{InactivityPolicy{Mode: KeepInactive}, ErrAlreadyRegistered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			r := NewRegistry()
+			pub := sortitionKey("validator").Public().(ed25519.PublicKey)
+			address, err := r.RegisterKey(pub, 1000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.SetConnected(address, false)
+			for round := 1; round <= 3; round++ {
+				r.ApplyInactivity(round, tt.policy)
+			}
+			if _, err := r.RegisterKey(pub, 1000); !errors.Is(err, tt.want) {
+				t.Fatalf("second registration: error %v, want %v", err, tt.want)
+			}
+			if supply := r.Supply(); supply > 1000 {
+				t.Fatalf("supply %d after a single grant of 1000", supply)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// ProtocolJSONL is the protocol name a client sends in its hello message to
+// switch the connection to JSON lines.
+const ProtocolJSONL = "jsonl"
+
+// RoleDelegator is the role a client registers with to open a delegator
+// account instead of a validator.
+const RoleDelegator = "delegator"
+
+// Message types of the JSON-lines protocol.
+const (
+	MsgHello       = "hello"        // client: {protocol}; server: {protocol, challenge}
+	MsgRegister    = "register"     // client: {public_key, role}
+	MsgResume      = "resume"       // client: {public_key, signature}
+	MsgRegistered  = "registered"   // server: {address, balance, round, reserve, seed, nonce, resumed}
+	MsgBid         = "bid"          // client: {round, nonce, bpm, bid, proof, signature}
+	MsgCommit      = "commit"       // client: {round, nonce, bpm, commitment, proof, signature}
+	MsgReveal      = "reveal"       // client: {round, nonce, bid, salt, signature}
+	MsgBond        = "bond"         // client: {nonce, amount, signature}
+	MsgUnbond      = "unbond"       // client: {nonce, amount, signature}
+	MsgDelegate    = "delegate"     // client: {nonce, validator, amount, signature}
+	MsgUndelegate  = "undelegate"   // client: {nonce, validator, amount, signature}
+	MsgRedelegate  = "redelegate"   // client: {nonce, from, validator, amount, signature}
+	MsgCommission  = "commission"   // client: {nonce, commission, signature}
+	MsgBidAck      = "bid_ack"      // server: {bpm, bid, round}
+	MsgBidRejected = "bid_rejected" // server: {reason}
+	MsgRoundOpen   = "round_open"   // server: {round, opened_at, closes_at, reserve, seed}
+	MsgRoundClose  = "round_close"  // server: {round, opened_at, closed_at}
+	MsgRoundResult = "round_result" // server: {round, opened_at, closed_at, reserve, winner, price, block, stats}
+	MsgBalance     = "balance"      // server: {balance}
+	MsgStake       = "stake"        // server: {balance, bonded, unbonding, delegated}
+	MsgChain       = "chain"        // server: {blocks}
+	MsgError       = "error"        // server: {reason}
+)
+
+// Message is the envelope of every JSON-lines message. Only the fields that
+// belong to Type are set.
+type Message struct {
+	Type       string      `json:"type"`
+	Protocol   string      `json:"protocol,omitempty"`
+	Challenge  string      `json:"challenge,omitempty"`
+	Resumed    bool        `json:"resumed,omitempty"`
+	Address    string      `json:"address,omitempty"`
+	PublicKey  string      `json:"public_key,omitempty"`
+	Role       string      `json:"role,omitempty"`
+	Balance    int         `json:"balance,omitempty"`
+	Round      int         `json:"round,omitempty"`
+	OpenedAt   string      `json:"opened_at,omitempty"`
+	ClosesAt   string      `json:"closes_at,omitempty"`
+	ClosedAt   string      `json:"closed_at,omitempty"`
+	Reserve    int         `json:"reserve,omitempty"`
+	Seed       string      `json:"seed,omitempty"`
+	Nonce      uint64      `json:"nonce,omitempty"`
+	BPM        int         `json:"bpm,omitempty"`
+	Bid        int         `json:"bid,omitempty"`
+	Amount     int         `json:"amount,omitempty"`
+	Bonded     int         `json:"bonded,omitempty"`
+	Unbonding  int         `json:"unbonding,omitempty"`
+	Delegated  int         `json:"delegated,omitempty"`
+	Validator  string      `json:"validator,omitempty"`
+	From       string      `json:"from,omitempty"`
+	Commission int         `json:"commission,omitempty"`
+	Commitment string      `json:"commitment,omitempty"`
+	Salt       string      `json:"salt,omitempty"`
+	Proof      string      `json:"proof,omitempty"`
+	Signature  string      `json:"signature,omitempty"`
+	Reason     string      `json:"reason,omitempty"`
+	Winner     string      `json:"winner,omitempty"`
+	Price      int         `json:"price,omitempty"`
+	Block      *Block      `json:"block,omitempty"`
+	Stats      *RoundStats `json:"stats,omitempty"`
+	Blocks     []Block     `json:"blocks,omitempty"`
+}
+
+var errNotRegistered = errors.New("expected a register or resume message")
+
+// isHello reports whether the first line of a connection asks for the
+// JSON-lines protocol.
+func isHello(line string) bool {
+	var msg Message
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		return false
+	}
+	return msg.Type == MsgHello && msg.Protocol == ProtocolJSONL
+}
+
+// jsonSession speaks the JSON-lines protocol: one Message per line in each
+// direction.
+type jsonSession struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	scanner *bufio.Scanner
+	enc     *json.Encoder
+	// challenge is sent in the server hello and must be signed by a
+	// validator that resumes an existing account.
+	challenge string
+}
+
+func newJSONSession(conn net.Conn, scanner *bufio.Scanner, challenge string) *jsonSession {
+	j := &jsonSession{conn: conn, scanner: scanner, enc: json.NewEncoder(conn), challenge: challenge}
+	// The legacy balance prompt has already been written without a trailing
+	// newline; terminate it so every JSON message starts on its own line.
+	io.WriteString(conn, "\n")
+	j.send(Message{Type: MsgHello, Protocol: ProtocolJSONL, Challenge: challenge})
+	return j
+}
+
+func (j *jsonSession) send(msg Message) {
+	j.mu.Lock()
+	j.enc.Encode(msg)
+	j.mu.Unlock()
+}
+
+// read returns the next well-formed message. Malformed lines are answered
+// with an error message and skipped.
+func (j *jsonSession) read() (Message, error) {
+	for j.scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(j.scanner.Bytes(), &msg); err != nil {
+			j.send(Message{Type: MsgError, Reason: "malformed message"})
+			continue
+		}
+		return msg, nil
+	}
+	if err := j.scanner.Err(); err != nil {
+		return Message{}, err
+	}
+	return Message{}, io.EOF
+}
+
+func (j *jsonSession) register() (registration, error) {
+	msg, err := j.read()
+	if err != nil {
+		return registration{}, err
+	}
+	if msg.Type != MsgRegister && msg.Type != MsgResume {
+		j.send(Message{Type: MsgError, Reason: "expected register or resume"})
+		return registration{}, errNotRegistered
+	}
+	reg := registration{Delegator: msg.Role == RoleDelegator}
+	if msg.PublicKey != "" {
+		if reg.PublicKey, err = ParsePublicKey(msg.PublicKey); err != nil {
+			j.send(Message{Type: MsgError, Reason: "malformed public key"})
+			return registration{}, err
+		}
+	}
+	if msg.Type == MsgResume {
+		reg.Resume, reg.Challenge = true, j.challenge
+		if reg.Signature, err = hex.DecodeString(msg.Signature); err != nil {
+			reg.Signature = nil
+		}
+	}
+	return reg, nil
+}
+
+func (j *jsonSession) registered(address string, balance, round, reserve int, seed []byte, nonce uint64, resumed bool) {
+	j.send(Message{Type: MsgRegistered, Address: address, Balance: balance, Round: round, Reserve: reserve, Seed: hex.EncodeToString(seed), Nonce: nonce, Resumed: resumed})
+}
+
+func (j *jsonSession) registrationFailed(reason string) {
+	j.send(Message{Type: MsgError, Reason: reason})
+}
+
+func (j *jsonSession) nextBid() (BidRequest, error) {
+	for {
+		msg, err := j.read()
+		if err != nil {
+			return BidRequest{}, err
+		}
+		req := BidRequest{Round: msg.Round, Nonce: msg.Nonce, BPM: msg.BPM, Bid: msg.Bid}
+		switch msg.Type {
+		case MsgBid:
+		case MsgCommit:
+			req.Kind = CommitBid
+			if req.Commitment, err = hex.DecodeString(msg.Commitment); err != nil {
+				return BidRequest{}, fmt.Errorf("%w: commitment is not hex", ErrMalformedBid)
+			}
+		case MsgReveal:
+			req.Kind = RevealBid
+			if req.Salt, err = hex.DecodeString(msg.Salt); err != nil {
+				return BidRequest{}, fmt.Errorf("%w: salt is not hex", ErrMalformedBid)
+			}
+		case MsgBond:
+			req.Kind, req.Bid = BondStake, msg.Amount
+		case MsgUnbond:
+			req.Kind, req.Bid = UnbondStake, msg.Amount
+		case MsgDelegate:
+			req.Kind, req.Bid, req.Validator = Delegate, msg.Amount, msg.Validator
+		case MsgUndelegate:
+			req.Kind, req.Bid, req.Validator = Undelegate, msg.Amount, msg.Validator
+		case MsgRedelegate:
+			req.Kind, req.Bid, req.Validator, req.From = Redelegate, msg.Amount, msg.Validator, msg.From
+		case MsgCommission:
+			req.Kind, req.Bid = SetCommission, msg.Commission
+		default:
+			j.send(Message{Type: MsgError, Reason: "unexpected message type " + msg.Type})
+			continue
+		}
+		if msg.Signature != "" {
+			if req.Signature, err = hex.DecodeString(msg.Signature); err != nil {
+				return BidRequest{}, fmt.Errorf("%w: signature is not hex", ErrMalformedBid)
+			}
+		}
+		if msg.Proof != "" {
+			if req.Proof, err = hex.DecodeString(msg.Proof); err != nil {
+				return BidRequest{}, fmt.Errorf("%w: proof is not hex", ErrMalformedBid)
+			}
+		}
+		return req, nil
+	}
+}
+
+func (j *jsonSession) bidAccepted(bpm, bid, round int) {
+	j.send(Message{Type: MsgBidAck, BPM: bpm, Bid: bid, Round: round})
+}
+
+func (j *jsonSession) bidRejected(reason string) {
+	j.send(Message{Type: MsgBidRejected, Reason: reason})
+}
+
+func (j *jsonSession) roundOpened(info RoundInfo) {
+	j.send(Message{Type: MsgRoundOpen, Round: info.ID, OpenedAt: timestamp(info.OpenedAt), ClosesAt: timestamp(info.ClosesAt), Reserve: info.Reserve, Seed: hex.EncodeToString(info.Seed)})
+}
+
+func (j *jsonSession) roundClosed(info RoundInfo) {
+	j.send(Message{Type: MsgRoundClose, Round: info.ID, OpenedAt: timestamp(info.OpenedAt), ClosedAt: timestamp(info.ClosedAt)})
+}
+
+func (j *jsonSession) roundResult(result RoundResult) {
+	msg := Message{
+		Type:     MsgRoundResult,
+		Round:    result.Round,
+		OpenedAt: timestamp(result.OpenedAt),
+		ClosedAt: timestamp(result.ClosedAt),
+		Reserve:  result.Reserve,
+		Winner:   result.Winner,
+		Price:    result.Price,
+		Stats:    &result.Stats,
+	}
+	if result.Winner != "" {
+		block := result.Block
+		msg.Block = &block
+	}
+	j.send(msg)
+}
+
+// timestamp formats t for a message, leaving zero times out.
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func (j *jsonSession) balance(balance int) {
+	j.send(Message{Type: MsgBalance, Balance: balance})
+}
+
+func (j *jsonSession) staked(balance, bonded, unbonding, delegated int) {
+	j.send(Message{Type: MsgStake, Balance: balance, Bonded: bonded, Unbonding: unbonding, Delegated: delegated})
+}
+
+func (j *jsonSession) chain(blocks []Block) {
+	j.send(Message{Type: MsgChain, Blocks: blocks})
+}
@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// jsonLines returns a JSON-lines session that reads input and writes to the
+// returned buffer.
+func jsonLines(input string) (*jsonSession, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &jsonSession{scanner: bufio.NewScanner(strings.NewReader(input)), enc: json.NewEncoder(out), challenge: "c0ffee"}, out
+}
+
+// sentErrors returns the reason of every error message written to out.
+func sentErrors(t *testing.T, out *bytes.Buffer) []string {
+	t.Helper()
+	var reasons []string
+	dec := json.NewDecoder(out)
+	for {
+		var msg Message
+		if err := dec.Decode(&msg); err == io.EOF {
+			return reasons
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type == MsgError {
+			reasons = append(reasons, msg.Reason)
+		}
+	}
+}
+
+func TestIsHello(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{`{"type": "hello", "protocol": "jsonl"}`, true},
+		{`{"type": "hello", "protocol": "xml"}`, false},
+		{`{"type": "hello"}`, false},
+		{`{"type": "register", "protocol": "jsonl"}`, false},
+		{`{"type": "hello", "protocol": "jsonl"`, false},
+		{`1000`, false},
+		{``, false},
+	}
+	for _, tt := range tests {
+		if got := isHello(tt.line); got != tt.want {
+			t.Errorf("isHello(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestJSONSessionRegister(t *testing.T) {
+	pub := sortitionKey("validator").Public().(ed25519.PublicKey)
+	key := hex.EncodeToString(pub)
+	tests := []struct {
+		name  string
+		input string
+		want  registration
+		// err is the error register returns, if it has a sentinel;
+		// wantErr is set for every error.
+		err     error
+		wantErr bool
+		errors  []string
+	}{
+		{"register", `{"type": "register", "public_key": "` + key + `"}`,
+			registration{PublicKey: pub}, nil, false, nil},
+		{"register a delegator", `{"type": "register", "role": "delegator"}`,
+			registration{Delegator: true}, nil, false, nil},
+		{"resume", `{"type": "resume", "public_key": "` + key + `", "signature": "abcd"}`,
+			registration{PublicKey: pub, Resume: true, Challenge: "c0ffee", Signature: []byte{0xab, 0xcd}}, nil, false, nil},
+		{"resume with a signature that is not hex", `{"type": "resume", "public_key": "` + key + `", "signature": "zz"}`,
+			registration{PublicKey: pub, Resume: true, Challenge: "c0ffee"}, nil, false, nil},
+		{"malformed lines are skipped", "not json\n{\"type\": \"register\"}",
+			registration{}, nil, false, []string{"malformed message"}},
+		{"wrong field type", "{\"type\": \"register\", \"public_key\": 5}\n{\"type\": \"register\"}",
+			registration{}, nil, false, []string{"malformed message"}},
+		{"bid before registering", `{"type": "bid", "bpm": 70, "bid": 10}`,
+			registration{}, errNotRegistered, true, []string{"expected register or resume"}},
+		{"public key is not hex", `{"type": "register", "public_key": "xyz"}`,
+			registration{}, nil, true, []string{"malformed public key"}},
+		{"public key is too short", `{"type": "register", "public_key": "abcd"}`,
+			registration{}, nil, true, []string{"malformed public key"}},
+		{"connection closed", "not json\n", registration{}, io.EOF, true, []string{"malformed message"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, out := jsonLines(tt.input)
+			reg, err := session.register()
+			if (err != nil) != tt.wantErr || tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+			if err == nil && !reflect.DeepEqual(reg, tt.want) {
+				t.Fatalf("registration %+v, want %+v", reg, tt.want)
+			}
+			if got := sentErrors(t, out); !reflect.DeepEqual(got, tt.errors) {
+				t.Fatalf("sent errors %q, want %q", got, tt.errors)
+			}
+		})
+	}
+}
+
+func TestJSONSessionNextBid(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		want   BidRequest
+		err    error
+		errors []string
+	}{
+		{"bid", `{"type": "bid", "round": 3, "nonce": 7, "bpm": 70, "bid": 10, "proof": "01", "signature": "02"}`,
+			BidRequest{Round: 3, Nonce: 7, BPM: 70, Bid: 10, Proof: []byte{1}, Signature: []byte{2}}, nil, nil},
+		{"commit", `{"type": "commit", "round": 3, "bpm": 70, "commitment": "abcd"}`,
+			BidRequest{Kind: CommitBid, Round: 3, BPM: 70, Commitment: []byte{0xab, 0xcd}}, nil, nil},
+		{"reveal", `{"type": "reveal", "round": 3, "bid": 10, "salt": "ff"}`,
+			BidRequest{Kind: RevealBid, Round: 3, Bid: 10, Salt: []byte{0xff}}, nil, nil},
+		{"bond", `{"type": "bond", "nonce": 1, "amount": 50}`,
+			BidRequest{Kind: BondStake, Nonce: 1, Bid: 50}, nil, nil},
+		{"redelegate", `{"type": "redelegate", "from": "v1", "validator": "v2", "amount": 50}`,
+			BidRequest{Kind: Redelegate, Bid: 50, Validator: "v2", From: "v1"}, nil, nil},
+		{"commission", `{"type": "commission", "commission": 1500}`,
+			BidRequest{Kind: SetCommission, Bid: 1500}, nil, nil},
+		{"malformed lines are skipped", "{\"type\": \"bid\"\n\n{\"type\": \"bid\", \"bid\": 10}",
+			BidRequest{Bid: 10}, nil, []string{"malformed message", "malformed message"}},
+		{"wrong field type", "{\"type\": \"bid\", \"bid\": \"10\"}\n{\"type\": \"bid\", \"bid\": 10}",
+			BidRequest{Bid: 10}, nil, []string{"malformed message"}},
+		{"negative nonce", "{\"type\": \"bid\", \"nonce\": -1}\n{\"type\": \"bid\", \"bid\": 10}",
+			BidRequest{Bid: 10}, nil, []string{"malformed message"}},
+		{"unknown types are skipped", "{\"type\": \"ping\"}\n{}\n{\"type\": \"bid\", \"bid\": 10}",
+			BidRequest{Bid: 10}, nil, []string{"unexpected message type ping", "unexpected message type "}},
+		{"commitment is not hex", `{"type": "commit", "commitment": "xyz"}`, BidRequest{}, ErrMalformedBid, nil},
+		{"salt is not hex", `{"type": "reveal", "salt": "abc"}`, BidRequest{}, ErrMalformedBid, nil},
+		{"signature is not hex", `{"type": "bid", "signature": "zz"}`, BidRequest{}, ErrMalformedBid, nil},
+		{"proof is not hex", `{"type": "bid", "proof": "zz"}`, BidRequest{}, ErrMalformedBid, nil},
+		{"connection closed", "", BidRequest{}, io.EOF, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, out := jsonLines(tt.input)
+			req, err := session.nextBid()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(req, tt.want) {
+				t.Fatalf("request %+v, want %+v", req, tt.want)
+			}
+			if got := sentErrors(t, out); !reflect.DeepEqual(got, tt.errors) {
+				t.Fatalf("sent errors %q, want %q", got, tt.errors)
+			}
+			if err != nil && err != io.EOF && rejectReason(err) != "malformed_bid" {
+				t.Fatalf("reason %q, want malformed_bid", rejectReason(err))
+			}
+		})
+	}
+}
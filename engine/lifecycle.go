@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrRoundClosed is returned for bids that arrive while no round is open
+// and the LatePolicy rejects them.
+var ErrRoundClosed = errors.New("bidding is closed for this round")
+
+// ErrLateBidWaiting is returned for a late bid from a validator that already
+// has one waiting for the next round under CarryLate.
+var ErrLateBidWaiting = errors.New("a late bid is already waiting for the next round")
+
+// LatePolicy decides what happens to a bid that arrives after its round
+// closed for bidding.
+type LatePolicy int
+
+const (
+	// RejectLate turns late bids away with ErrRoundClosed.
+	RejectLate LatePolicy = iota
+	// CarryLate holds late bids and submits them when the next round opens,
+	// one per validator.
+	CarryLate
+)
+
+// ParseLatePolicy parses "reject" or "carry".
+func ParseLatePolicy(name string) (LatePolicy, error) {
+	switch name {
+	case "reject":
+		return RejectLate, nil
+	case "carry":
+		return CarryLate, nil
+	default:
+		return 0, fmt.Errorf("unknown late bid policy %q (available: reject, carry)", name)
+	}
+}
+
+func (p LatePolicy) String() string {
+	if p == CarryLate {
+		return "carry"
+	}
+	return "reject"
+}
+
+// RoundPhase is a step in a round's lifecycle: it opens for bids, closes,
+// and is settled after the settlement delay.
+type RoundPhase int
+
+const (
+	PhaseOpen RoundPhase = iota
+	PhaseClosed
+	PhaseSettled
+)
+
+// RoundInfo identifies a round and its bidding window. ClosesAt is when the
+// round is scheduled to close; ClosedAt is zero until it has.
+type RoundInfo struct {
+	ID       int
+	OpenedAt time.Time
+	ClosesAt time.Time
+	ClosedAt time.Time
+	// Reserve is the minimum bid of the round.
+	Reserve int
+	// Seed is the round's sortition seed, in rounds run by Sortition.
+	Seed []byte
+}
+
+// RoundEvent is what the server announces to validators at each phase.
+// Result is only set for PhaseSettled.
+type RoundEvent struct {
+	Phase  RoundPhase
+	Round  RoundInfo
+	Result RoundResult
+}
@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestCarryLate checks that late bids are authorized when they arrive, that
+// a validator may have only one waiting, and that the ones held are
+// submitted when the next round opens.
+func TestCarryLate(t *testing.T) {
+	rounds, registry, key, address := testRounds(t, NewVickreyLottery(), BidRules{Late: CarryLate}, Economics{})
+	registry.Register("legacy", 1000)
+	rounds.Settle()
+	bid := BidRequest{BPM: 70, Bid: 10}
+	legacy := BidRequest{Address: "legacy", BPM: 70, Bid: 10}
+
+	steps := []struct {
+		name  string
+		req   BidRequest
+		round int
+		err   error
+	}{
+		{"signed by another key", signed(sortitionKey("other"), address, 1, 1, bid), 1, ErrBadSignature},
+		{"unknown validator", BidRequest{Address: "nobody", BPM: 70, Bid: 10}, 1, ErrUnknownValidator},
+		{"signed", signed(key, address, 1, 1, bid), 2, nil},
+		{"replayed", signed(key, address, 1, 1, bid), 1, ErrStaleNonce},
+		{"second bid from the same validator", signed(key, address, 1, 2, bid), 1, ErrLateBidWaiting},
+		{"keyless", legacy, 2, nil},
+		{"second keyless bid", legacy, 1, ErrLateBidWaiting},
+	}
+	for _, step := range steps {
+		round, err := rounds.SubmitBid(step.req)
+		if !errors.Is(err, step.err) || round != step.round {
+			t.Fatalf("%s: round %d, error %v; want round %d, error %v", step.name, round, err, step.round, step.err)
+		}
+	}
+	if len(rounds.late) != 2 {
+		t.Fatalf("%d bids waiting, want 2", len(rounds.late))
+	}
+	if nonce := registry.Nonce(address); nonce != 2 {
+		t.Fatalf("nonce %d after the late bids, want 2", nonce)
+	}
+
+	rounds.Open()
+	result, _ := rounds.Settle()
+	if result.Stats.Carried != 2 || result.Stats.Accepted != 2 {
+		t.Fatalf("round 2 carried %d and accepted %d bids, want 2 and 2", result.Stats.Carried, result.Stats.Accepted)
+	}
+	if _, err := rounds.SubmitBid(legacy); err != nil {
+		t.Fatalf("late bid after the queue was emptied: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package engine
+
+import "math/rand"
+
+// BalanceLottery picks the winner among the round's proposers with
+// probability proportional to their balance, or to Round.Weights when set.
+// Bids are never refunded.
+type BalanceLottery struct{}
+
+// NewBalanceLottery returns the stake-weighted lottery used by the Random
+// and Random_gen variants.
+func NewBalanceLottery() *BalanceLottery {
+	return &BalanceLottery{}
+}
+
+// Name implements SelectionMechanism.
+func (l *BalanceLottery) Name() string {
+	return "random"
+}
+
+// Select implements SelectionMechanism.
+func (l *BalanceLottery) Select(round Round, rng *rand.Rand) Outcome {
+	outcome := l.SelectCommittee(round, 1, rng)
+	outcome.Committee = nil
+	return outcome
+}
+
+// SelectCommittee draws k distinct proposers, each by the same lottery over
+// the proposers not drawn yet. Every bid is forfeited, as in Select.
+//
+// Each draw picks a proposer with probability weight/total, exactly as if it
+// indexed a pool holding every proposer once per token, in the order the
+// proposers first appear among the candidates. The draws search the running
+// totals of the weights instead, kept in a tree built once per round, so
+// balances of any size cost nothing extra and each seat costs O(log n).
+func (l *BalanceLottery) SelectCommittee(round Round, k int, rng *rand.Rand) Outcome {
+	outcome := Outcome{Payments: escrowedBids(round.Bids)}
+
+	proposers := make([]string, 0, len(round.Candidates))
+	weights := make([]int, 0, len(round.Candidates))
+	seen := make(map[string]bool, len(round.Candidates))
+	for _, block := range round.Candidates {
+		if seen[block.Proposer] {
+			continue
+		}
+		seen[block.Proposer] = true
+		weight := round.Balances[block.Proposer]
+		if round.Weights != nil {
+			weight = round.Weights[block.Proposer]
+		}
+		proposers = append(proposers, block.Proposer)
+		weights = append(weights, weight)
+	}
+
+	pool := newWeightTree(weights)
+	for len(outcome.Committee) < k {
+		// Nobody holds stake once every proposer has bid its balance away.
+		i := pool.draw(rng)
+		if i < 0 {
+			break
+		}
+		pool.remove(i)
+		outcome.Committee = append(outcome.Committee, proposers[i])
+	}
+	if len(outcome.Committee) > 0 {
+		outcome.Winner = outcome.Committee[0]
+	}
+
+	return outcome
+}
+
+// weightTree draws indexes with probability proportional to their weight,
+// from a Fenwick tree over the weights, so that both a draw and removing the
+// index drawn take O(log n). Indexes without a positive weight are never
+// drawn. When the weights add up to more than maxWeightTotal they are all
+// scaled down by the same factor, as Weighted does, so that the total fits
+// in an int and the odds stay proportional to the weights.
+type weightTree struct {
+	weights []int
+	// tree[i] holds the sum of the weights of the indexes in (i-i&-i, i],
+	// counting from one.
+	tree  []int
+	total int
+}
+
+func newWeightTree(weights []int) *weightTree {
+	t := &weightTree{weights: make([]int, len(weights)), tree: make([]int, len(weights)+1)}
+	sum := 0.0
+	for _, weight := range weights {
+		if weight > 0 {
+			sum += float64(weight)
+		}
+	}
+	scale := 1.0
+	if sum > maxWeightTotal {
+		scale = maxWeightTotal / sum
+	}
+	for i, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		if scale < 1 {
+			weight = int(float64(weight) * scale)
+		}
+		t.weights[i] = weight
+		t.total += weight
+	}
+	for i := 1; i < len(t.tree); i++ {
+		t.tree[i] += t.weights[i-1]
+		if parent := i + i&-i; parent < len(t.tree) {
+			t.tree[parent] += t.tree[i]
+		}
+	}
+	return t
+}
+
+// draw returns a random index, or -1 when there is none to draw. Index i is
+// drawn when a uniform threshold falls within its share of the running
+// total, in index order.
+func (t *weightTree) draw(rng *rand.Rand) int {
+	if t.total <= 0 {
+		return -1
+	}
+	threshold := rng.Intn(t.total)
+	// Find the longest prefix whose weights add up to at most threshold;
+	// the index right after it is the one drawn.
+	pos := 0
+	for step := highBit(len(t.weights)); step > 0; step >>= 1 {
+		if next := pos + step; next < len(t.tree) && t.tree[next] <= threshold {
+			pos = next
+			threshold -= t.tree[next]
+		}
+	}
+	return pos
+}
+
+// remove takes index i out of later draws.
+func (t *weightTree) remove(i int) {
+	weight := t.weights[i]
+	if weight == 0 {
+		return
+	}
+	t.weights[i] = 0
+	t.total -= weight
+	for j := i + 1; j < len(t.tree); j += j & -j {
+		t.tree[j] -= weight
+	}
+}
+
+// highBit returns the largest power of two not above n, or 0.
+func highBit(n int) int {
+	bit := 0
+	for step := 1; step > 0 && step <= n; step <<= 1 {
+		bit = step
+	}
+	return bit
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// lotteryRound builds a round of n proposers with balances drawn up to
+// maxBalance, some of them zero.
+func lotteryRound(n, maxBalance int, rng *rand.Rand) Round {
+	round := Round{Candidates: make([]Block, n), Balances: make(map[string]int, n)}
+	for i := range round.Candidates {
+		addr := fmt.Sprintf("validator-%d", i)
+		round.Candidates[i].Proposer = addr
+		round.Balances[addr] = rng.Intn(maxBalance + 1)
+	}
+	return round
+}
+
+// poolDraw is the original lottery: a pool holding every proposer once per
+// token, indexed uniformly.
+func poolDraw(round Round, rng *rand.Rand) string {
+	var pool []string
+	for _, block := range round.Candidates {
+		for i := 0; i < round.Balances[block.Proposer]; i++ {
+			pool = append(pool, block.Proposer)
+		}
+	}
+	if len(pool) == 0 {
+		return ""
+	}
+	return pool[rng.Intn(len(pool))]
+}
+
+func TestBalanceLotteryMatchesPool(t *testing.T) {
+	lottery := NewBalanceLottery()
+	for seed := int64(0); seed < 200; seed++ {
+		round := lotteryRound(8, 50, rand.New(rand.NewSource(seed)))
+		want := poolDraw(round, rand.New(rand.NewSource(seed)))
+		got := lottery.Select(round, rand.New(rand.NewSource(seed))).Winner
+		if got != want {
+			t.Fatalf("seed %d: winner %q, the pool draws %q", seed, got, want)
+		}
+	}
+}
+
+func TestBalanceLotteryCommitteeMatchesPool(t *testing.T) {
+	lottery := NewBalanceLottery()
+	for seed := int64(0); seed < 200; seed++ {
+		round := lotteryRound(8, 50, rand.New(rand.NewSource(seed)))
+		rng := rand.New(rand.NewSource(seed))
+		var want []string
+		left := round
+		left.Balances = make(map[string]int)
+		for addr, balance := range round.Balances {
+			left.Balances[addr] = balance
+		}
+		for len(want) < 4 {
+			member := poolDraw(left, rng)
+			if member == "" {
+				break
+			}
+			want = append(want, member)
+			left.Balances[member] = 0
+		}
+		got := lottery.SelectCommittee(round, 4, rand.New(rand.NewSource(seed))).Committee
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("seed %d: committee %v, the pool draws %v", seed, got, want)
+		}
+	}
+}
+
+func TestBalanceLotteryZeroBalances(t *testing.T) {
+	round := lotteryRound(5, 0, rand.New(rand.NewSource(1)))
+	outcome := NewBalanceLottery().Select(round, rand.New(rand.NewSource(1)))
+	if outcome.Winner != "" {
+		t.Fatalf("winner %q without any stake", outcome.Winner)
+	}
+}
+
+func BenchmarkBalanceLottery(b *testing.B) {
+	lottery := NewBalanceLottery()
+	for _, n := range []int{1000, 100000, 1000000, 4000000} {
+		b.Run(fmt.Sprintf("validators=%d", n), func(b *testing.B) {
+			round := lotteryRound(n, 1e9, rand.New(rand.NewSource(1)))
+			rng := rand.New(rand.NewSource(1))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lottery.Select(round, rng)
+			}
+		})
+	}
+}
+
+func BenchmarkBalanceLotteryCommittee(b *testing.B) {
+	lottery := NewBalanceLottery()
+	round := lotteryRound(100000, 1e9, rand.New(rand.NewSource(1)))
+	for _, k := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("seats=%d", k), func(b *testing.B) {
+			rng := rand.New(rand.NewSource(1))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lottery.SelectCommittee(round, k, rng)
+			}
+		})
+	}
+}
+
+// TestBalanceLotteryHugeTotals checks that stakes adding up to more than an
+// int holds still win in proportion to their size.
+func TestBalanceLotteryHugeTotals(t *testing.T) {
+	tests := []struct {
+		name     string
+		balances map[string]int
+		// want is each validator's share of the wins.
+		want map[string]float64
+	}{
+		{"equal stakes", map[string]int{"a": math.MaxInt, "b": math.MaxInt, "c": -5}, map[string]float64{"a": 0.5, "b": 0.5}},
+		{"three equal stakes", map[string]int{"a": math.MaxInt / 2, "b": math.MaxInt / 2, "c": math.MaxInt / 2}, map[string]float64{"a": 1.0 / 3, "b": 1.0 / 3, "c": 1.0 / 3}},
+		{"one stake three times another", map[string]int{"a": math.MaxInt / 4, "b": math.MaxInt / 4 * 3, "c": math.MaxInt / 2}, map[string]float64{"a": 1.0 / 6, "b": 0.5, "c": 1.0 / 3}},
+	}
+	const draws = 20000
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			round := Round{Balances: tt.balances}
+			for _, addr := range []string{"a", "b", "c"} {
+				round.Candidates = append(round.Candidates, Block{Proposer: addr})
+			}
+			rng := rand.New(rand.NewSource(1))
+			wins := make(map[string]int)
+			for i := 0; i < draws; i++ {
+				wins[NewBalanceLottery().Select(round, rng).Winner]++
+			}
+			for addr, want := range tt.want {
+				if got := float64(wins[addr]) / draws; math.Abs(got-want) > 0.02 {
+					t.Fatalf("%s won %.3f of the draws, want %.3f", addr, got, want)
+				}
+			}
+			if wins["c"] > 0 && tt.want["c"] == 0 {
+				t.Fatalf("c won %d draws without stake", wins["c"])
+			}
+		})
+	}
+}
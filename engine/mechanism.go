@@ -0,0 +1,70 @@
+package engine
+
+import "math/rand"
+
+// BidItem is a single bid submitted by a validator during a round.
+type BidItem struct {
+	NodeAddress string
+	Bid         int
+}
+
+// Round is the input a selection mechanism sees when a round is settled.
+// Balances holds the spendable balance of every bidder after escrow. Reserve
+// is the least a winner may be charged; every bid is at least that much.
+// Weights, when set, is the selection weight of every bidder, and lotteries
+// draw by it instead of by bids or balances. Seed and Tickets are the round's
+// sortition seed and the verified proof of every bidder, for Sortition.
+type Round struct {
+	Bids       []BidItem
+	Candidates []Block
+	Balances   map[string]int
+	Reserve    int
+	Weights    map[string]int
+	Seed       []byte
+	Tickets    map[string]Ticket
+}
+
+// Outcome is the result of running a selection mechanism over a round.
+// Payments lists how much of its escrowed bid each validator forfeits;
+// validators that are not listed get their escrow back in full. Committee,
+// when a mechanism picks several validators, lists them all, leader first;
+// Winner is the leader and Price what it paid. Sortition is the record a
+// sortition round leaves in its block.
+type Outcome struct {
+	Winner    string
+	Price     int
+	Payments  map[string]int
+	Committee []string
+	Sortition *SortitionRecord
+}
+
+// SelectionMechanism decides which validator seals the next block and what
+// every bidder pays for the privilege. Every random draw must come from rng so
+// that a run can be replayed from its seed.
+type SelectionMechanism interface {
+	Name() string
+	Select(round Round, rng *rand.Rand) Outcome
+}
+
+// escrowedBids sums the bids of each validator in the round.
+func escrowedBids(bids []BidItem) map[string]int {
+	escrow := make(map[string]int)
+	for _, bidItem := range bids {
+		escrow[bidItem.NodeAddress] += bidItem.Bid
+	}
+	return escrow
+}
+
+func selectBlockForWinner(blocks []Block, winner string) Block {
+	if len(blocks) == 0 {
+		return Block{}
+	}
+
+	for _, block := range blocks {
+		if block.Proposer == winner {
+			return block
+		}
+	}
+
+	return blocks[0]
+}
@@ -0,0 +1,40 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MechanismFactory builds a selection mechanism for a run configured by cfg.
+type MechanismFactory func(cfg Config) SelectionMechanism
+
+// mechanisms is every mechanism -mechanism can select, by Name.
+var mechanisms = map[string]MechanismFactory{
+	"vickrey":      func(Config) SelectionMechanism { return NewVickreyLottery() },
+	"random":       func(Config) SelectionMechanism { return NewBalanceLottery() },
+	"second-price": func(Config) SelectionMechanism { return NewSecondPrice() },
+	"first-price":  func(Config) SelectionMechanism { return NewFirstPrice() },
+	"all-pay":      func(Config) SelectionMechanism { return NewAllPay() },
+	"english":      func(cfg Config) SelectionMechanism { return NewEnglishClock(cfg.Bids.Granularity) },
+	"sortition":    func(cfg Config) SelectionMechanism { return NewSortition(cfg.SortitionSize) },
+}
+
+// MechanismNames lists the selectable mechanisms for help messages.
+func MechanismNames() string {
+	names := make([]string, 0, len(mechanisms))
+	for name := range mechanisms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// NewMechanism builds the named mechanism.
+func NewMechanism(name string, cfg Config) (SelectionMechanism, error) {
+	factory, ok := mechanisms[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown mechanism %q (available: %s)", name, MechanismNames())
+	}
+	return factory(cfg), nil
+}
@@ -0,0 +1,281 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"sync"
+)
+
+// Node is a registered validator. Bid holds the tokens escrowed for the
+// current round; they have already been deducted from Balance. Validators
+// that registered a PublicKey must sign their bids with strictly increasing
+// nonces; Nonce is the last one accepted. LastSeen is the last round that
+// settled while the validator was connected. Ledger accumulates what it has
+// earned and paid. Bonded and Unbonding are locked stake; see Bonding. A
+// slashed validator may be jailed until round JailedUntil or Ejected. A
+// Delegator does not bid but stakes Delegations through validators, which
+// keep Commission basis points of the rewards they pass on.
+type Node struct {
+	Address     string
+	Balance     int
+	Bid         int
+	PublicKey   ed25519.PublicKey
+	Nonce       uint64
+	Connected   bool
+	Frozen      bool
+	LastSeen    int
+	Ledger      Ledger
+	Bonded      int
+	Unbonding   []Unbonding
+	JailedUntil int
+	Ejected     bool
+	Delegator   bool
+	Delegations map[string]int
+	Commission  int
+}
+
+// Registry tracks every validator that has connected to the server, and the
+// treasury that takes a share of what rounds collect. Evicted accounts leave
+// a tombstone in evicted, so that their key cannot register for a second
+// starting grant.
+type Registry struct {
+	mu       sync.Mutex
+	nodes    map[string]*Node
+	evicted  map[string]bool
+	treasury int
+}
+
+// NewRegistry returns an empty validator registry.
+func NewRegistry() *Registry {
+	return &Registry{nodes: make(map[string]*Node), evicted: make(map[string]bool)}
+}
+
+// Register adds a validator with the given starting balance.
+func (r *Registry) Register(address string, balance int) {
+	r.mu.Lock()
+	r.nodes[address] = &Node{Address: address, Balance: balance, Bid: 0, Connected: true}
+	r.mu.Unlock()
+}
+
+// RegisterKey adds a validator identified by its Ed25519 public key and
+// returns the address derived from it.
+func (r *Registry) RegisterKey(pub ed25519.PublicKey, balance int) (string, error) {
+	address := AddressFromKey(pub)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.nodes[address]; ok {
+		return "", ErrAlreadyRegistered
+	}
+	if r.evicted[address] {
+		return "", ErrEvicted
+	}
+	r.nodes[address] = &Node{Address: address, Balance: balance, PublicKey: pub, Connected: true}
+	return address, nil
+}
+
+// Resume reattaches a reconnecting validator to its existing account,
+// keeping its balance and escrowed bid and lifting any freeze. The caller
+// must have authenticated the key and marks the account Connected.
+func (r *Registry) Resume(pub ed25519.PublicKey) (string, error) {
+	address := AddressFromKey(pub)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, ok := r.nodes[address]
+	if !ok || !bytes.Equal(node.PublicKey, pub) {
+		return "", ErrUnknownValidator
+	}
+	node.Frozen = false
+	return address, nil
+}
+
+// SetConnected records whether a validator currently has a live connection.
+// A disconnected account stays in the registry until the inactivity policy
+// says otherwise.
+func (r *Registry) SetConnected(address string, connected bool) {
+	r.mu.Lock()
+	if node, ok := r.nodes[address]; ok {
+		node.Connected = connected
+	}
+	r.mu.Unlock()
+}
+
+// PublicKey returns the key a validator registered, if any.
+func (r *Registry) PublicKey(address string) (ed25519.PublicKey, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, ok := r.nodes[address]
+	if !ok || node.PublicKey == nil {
+		return nil, false
+	}
+	return node.PublicKey, true
+}
+
+// Authorize checks the signature and nonce of a bid from a validator with a
+// registered key and records the nonce. Bids from keyless validators, which
+// registered through the legacy text protocol, are accepted unchecked.
+func (r *Registry) Authorize(req BidRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, ok := r.nodes[req.Address]
+	if !ok {
+		return ErrUnknownValidator
+	}
+	if node.PublicKey == nil {
+		return nil
+	}
+	if !VerifyBid(node.PublicKey, req) {
+		return ErrBadSignature
+	}
+	if req.Nonce <= node.Nonce {
+		return ErrStaleNonce
+	}
+	node.Nonce = req.Nonce
+	return nil
+}
+
+// Nonce returns the last accepted bid nonce of a validator.
+func (r *Registry) Nonce(address string) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if node, ok := r.nodes[address]; ok {
+		return node.Nonce
+	}
+	return 0
+}
+
+// Balance returns the spendable balance of a validator.
+func (r *Registry) Balance(address string) (int, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, ok := r.nodes[address]
+	if !ok {
+		return 0, false
+	}
+	return node.Balance, true
+}
+
+// Escrow moves amount from the validator's balance into its bid for the
+// current round. It fails when the balance does not cover the amount.
+func (r *Registry) Escrow(address string, amount int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, ok := r.nodes[address]
+	if !ok || node.Balance < amount {
+		return false
+	}
+	node.Balance -= amount
+	node.Bid += amount
+	return true
+}
+
+// Release returns a validator's escrowed bid to its balance and then charges
+// payment against it. It returns the amount actually charged.
+func (r *Registry) Release(address string, payment int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, ok := r.nodes[address]
+	if !ok {
+		return 0
+	}
+	node.Balance += node.Bid
+	node.Bid = 0
+	if payment > node.Balance {
+		payment = node.Balance
+	}
+	node.Balance -= payment
+	node.Ledger.Payments += payment
+	return payment
+}
+
+// Credit adds a share of other validators' payments to a validator's
+// balance.
+func (r *Registry) Credit(address string, amount int) {
+	r.mu.Lock()
+	if node, ok := r.nodes[address]; ok {
+		node.Balance += amount
+		node.Ledger.Redistributed += amount
+	}
+	r.mu.Unlock()
+}
+
+// Reward records a block won by a validator and credits its reward.
+func (r *Registry) Reward(address string, amount int) {
+	r.mu.Lock()
+	if node, ok := r.nodes[address]; ok {
+		node.Ledger.Wins++
+		for holder, share := range r.shareReward(node, amount) {
+			holder.Balance += share
+			holder.Ledger.Rewards += share
+		}
+	}
+	r.mu.Unlock()
+}
+
+// Ledgers returns a snapshot of every validator's ledger by address.
+func (r *Registry) Ledgers() map[string]Ledger {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ledgers := make(map[string]Ledger, len(r.nodes))
+	for address, node := range r.nodes {
+		ledgers[address] = node.Ledger
+	}
+	return ledgers
+}
+
+// AddTreasury pays amount into the treasury.
+func (r *Registry) AddTreasury(amount int) {
+	r.mu.Lock()
+	r.treasury += amount
+	r.mu.Unlock()
+}
+
+// Treasury returns the treasury balance.
+func (r *Registry) Treasury() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.treasury
+}
+
+// Supply returns every token in existence: balances and escrow of all
+// accounts, frozen ones included, plus the treasury.
+func (r *Registry) Supply() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	supply := r.treasury
+	for _, node := range r.nodes {
+		supply += node.holdings() + node.Bid
+	}
+	return supply
+}
+
+// holdings is everything a validator owns outside the current round's
+// escrow: its balance and its bonded and unbonding stake.
+func (n *Node) holdings() int {
+	holdings := n.Balance + n.Bonded + n.delegations()
+	for _, entry := range n.Unbonding {
+		holdings += entry.Amount
+	}
+	return holdings
+}
+
+// Balances returns a snapshot of the holdings of every validator that is not
+// frozen. This is the population the fairness metrics are computed over.
+func (r *Registry) Balances() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	balances := make([]int, 0, len(r.nodes))
+	for _, node := range r.nodes {
+		if node.Frozen {
+			continue
+		}
+		balances = append(balances, node.holdings())
+	}
+	return balances
+}
+
+// Len returns the number of registered validators.
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.nodes)
+}
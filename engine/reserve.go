@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrBelowReserve is returned for a bid under the current reserve price.
+var ErrBelowReserve = errors.New("bid is below the reserve price")
+
+// ReserveMode is how the reserve price of a round is set.
+type ReserveMode int
+
+const (
+	// NoReserve leaves clearing prices without a floor.
+	NoReserve ReserveMode = iota
+	// StaticReserve keeps the reserve at Price for the whole run.
+	StaticReserve
+	// AdaptiveReserve starts at Price and moves with participation like an
+	// EIP-1559 base fee: up when more than Target validators bid in a round,
+	// down when fewer do.
+	AdaptiveReserve
+)
+
+// reserveChangeDenominator bounds how far an adaptive reserve moves in one
+// round: by at most 1/8 of itself, as in EIP-1559.
+const reserveChangeDenominator = 8
+
+// ReservePolicy sets the minimum bid of every round. The winner of a round
+// pays at least the reserve in force when the round opened.
+type ReservePolicy struct {
+	Mode   ReserveMode
+	Price  int
+	Target int
+}
+
+// ParseReservePolicy parses "none", "static:PRICE" or
+// "adaptive:PRICE:TARGET", where TARGET is the number of bids per round at
+// which an adaptive reserve stays put.
+func ParseReservePolicy(spec string) (ReservePolicy, error) {
+	parts := strings.Split(spec, ":")
+	policy := ReservePolicy{}
+	switch {
+	case spec == "none":
+		return policy, nil
+	case parts[0] == "static" && len(parts) == 2:
+		policy.Mode = StaticReserve
+	case parts[0] == "adaptive" && len(parts) == 3:
+		policy.Mode = AdaptiveReserve
+		target, err := strconv.Atoi(parts[2])
+		if err != nil || target < 1 {
+			return policy, fmt.Errorf("reserve policy %q: invalid target", spec)
+		}
+		policy.Target = target
+	default:
+		return policy, fmt.Errorf("unknown reserve policy %q (available: none, static:PRICE, adaptive:PRICE:TARGET)", spec)
+	}
+	price, err := strconv.Atoi(parts[1])
+	if err != nil || price < 0 {
+		return policy, fmt.Errorf("reserve policy %q: invalid price", spec)
+	}
+	policy.Price = price
+	return policy, nil
+}
+
+func (p ReservePolicy) String() string {
+	switch p.Mode {
+	case StaticReserve:
+		return "static:" + strconv.Itoa(p.Price)
+	case AdaptiveReserve:
+		return fmt.Sprintf("adaptive:%d:%d", p.Price, p.Target)
+	default:
+		return "none"
+	}
+}
+
+// Next returns the reserve of the round after one that ran at reserve and
+// received bids bids.
+func (p ReservePolicy) Next(reserve, bids int) int {
+	if p.Mode != AdaptiveReserve {
+		return reserve
+	}
+	// Like gas used under EIP-1559, participation counts for at most twice
+	// the target, which keeps each step within 1/8 of the reserve.
+	if bids > 2*p.Target {
+		bids = 2 * p.Target
+	}
+	delta := reserve * (bids - p.Target) / p.Target / reserveChangeDenominator
+	switch {
+	case bids > p.Target && delta < 1:
+		delta = 1
+	case bids < p.Target && delta > -1:
+		delta = -1
+	}
+	// Bids must be positive anyway, and a reserve of 0 could never rise.
+	if reserve += delta; reserve < 1 {
+		reserve = 1
+	}
+	return reserve
+}
+
+// withReserve raises a clearing price to the reserve.
+func withReserve(price, reserve int) int {
+	if price < reserve {
+		return reserve
+	}
+	return price
+}
@@ -0,0 +1,89 @@
+package engine
+
+import "testing"
+
+func TestParseReservePolicy(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    ReservePolicy
+		wantErr bool
+	}{
+		{"none", ReservePolicy{}, false},
+		{"static:10", ReservePolicy{Mode: StaticReserve, Price: 10}, false},
+		{"static:0", ReservePolicy{Mode: StaticReserve}, false},
+		{"adaptive:10:4", ReservePolicy{Mode: AdaptiveReserve, Price: 10, Target: 4}, false},
+		{"", ReservePolicy{}, true},
+		{"static", ReservePolicy{}, true},
+		{"static:-1", ReservePolicy{}, true},
+		{"static:ten", ReservePolicy{}, true},
+		{"static:10:4", ReservePolicy{}, true},
+		{"adaptive:10", ReservePolicy{}, true},
+		{"adaptive:10:0", ReservePolicy{}, true},
+		{"adaptive:x:4", ReservePolicy{}, true},
+		{"dynamic:10", ReservePolicy{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			policy, err := ParseReservePolicy(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if policy != tt.want {
+				t.Fatalf("parsed %+v, want %+v", policy, tt.want)
+			}
+			if policy.String() != tt.spec {
+				t.Fatalf("String() = %q, want %q", policy.String(), tt.spec)
+			}
+		})
+	}
+}
+
+func TestReservePolicyNext(t *testing.T) {
+	adaptive := ReservePolicy{Mode: AdaptiveReserve, Price: 80, Target: 4}
+	tests := []struct {
+		name    string
+		policy  ReservePolicy
+		reserve int
+		bids    int
+		want    int
+	}{
+		{"none stays put", ReservePolicy{}, 0, 10, 0},
+		{"static stays put", ReservePolicy{Mode: StaticReserve, Price: 80}, 80, 0, 80},
+		{"at the target", adaptive, 80, 4, 80},
+		{"above the target", adaptive, 80, 6, 85},
+		{"twice the target rises by 1/8", adaptive, 80, 8, 90},
+		{"rise is capped at 1/8", adaptive, 80, 100, 90},
+		{"below the target", adaptive, 80, 2, 75},
+		{"no bids falls by 1/8", adaptive, 80, 0, 70},
+		{"small reserve still rises", adaptive, 3, 5, 4},
+		{"small reserve still falls", adaptive, 3, 3, 2},
+		{"never below 1", adaptive, 1, 0, 1},
+		{"zero reserve rises", adaptive, 0, 5, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Next(tt.reserve, tt.bids); got != tt.want {
+				t.Fatalf("Next(%d, %d) = %d, want %d", tt.reserve, tt.bids, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithReserve(t *testing.T) {
+	tests := []struct {
+		price, reserve, want int
+	}{
+		{5, 10, 10},
+		{10, 10, 10},
+		{15, 10, 15},
+		{0, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := withReserve(tt.price, tt.reserve); got != tt.want {
+			t.Errorf("withReserve(%d, %d) = %d, want %d", tt.price, tt.reserve, got, tt.want)
+		}
+	}
+}
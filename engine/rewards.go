@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// RewardMode is where the reward of a block comes from.
+type RewardMode int
+
+const (
+	// NoReward pays nothing for winning a block.
+	NoReward RewardMode = iota
+	// FixedReward mints Amount to every block's winner.
+	FixedReward
+	// DecayingReward mints Amount to the first winner and halves the reward
+	// every Halving blocks.
+	DecayingReward
+	// FeeReward pays the winner the fee pool: every round, Txs synthetic
+	// transactions add a fee of 1 to MaxFee each. The pool carries over
+	// rounds that produce no block.
+	FeeReward
+)
+
+// feeStream is the DeriveSeed stream of the synthetic transactions, so that
+// drawing fees does not disturb the lottery or the simulated validators.
+const feeStream = -1
+
+// RewardPolicy decides what the winner of a block earns.
+type RewardPolicy struct {
+	Mode    RewardMode
+	Amount  int
+	Halving int
+	Txs     int
+	MaxFee  int
+}
+
+// ParseRewardPolicy parses "none", "fixed:N", "decay:N:HALVING" or
+// "fees:TXS:MAXFEE".
+func ParseRewardPolicy(spec string) (RewardPolicy, error) {
+	parts := strings.Split(spec, ":")
+	policy := RewardPolicy{}
+	numbers := make([]int, 0, 2)
+	for _, part := range parts[1:] {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 1 {
+			return policy, fmt.Errorf("reward policy %q: %q is not a positive number", spec, part)
+		}
+		numbers = append(numbers, n)
+	}
+	switch {
+	case spec == "none":
+	case parts[0] == "fixed" && len(numbers) == 1:
+		policy.Mode, policy.Amount = FixedReward, numbers[0]
+	case parts[0] == "decay" && len(numbers) == 2:
+		policy.Mode, policy.Amount, policy.Halving = DecayingReward, numbers[0], numbers[1]
+	case parts[0] == "fees" && len(numbers) == 2:
+		policy.Mode, policy.Txs, policy.MaxFee = FeeReward, numbers[0], numbers[1]
+	default:
+		return policy, fmt.Errorf("unknown reward policy %q (available: none, fixed:N, decay:N:HALVING, fees:TXS:MAXFEE)", spec)
+	}
+	return policy, nil
+}
+
+func (p RewardPolicy) String() string {
+	switch p.Mode {
+	case FixedReward:
+		return "fixed:" + strconv.Itoa(p.Amount)
+	case DecayingReward:
+		return fmt.Sprintf("decay:%d:%d", p.Amount, p.Halving)
+	case FeeReward:
+		return fmt.Sprintf("fees:%d:%d", p.Txs, p.MaxFee)
+	default:
+		return "none"
+	}
+}
+
+// issuance returns the newly minted reward of the given block, counting
+// from 1.
+func (p RewardPolicy) issuance(block int) int {
+	switch p.Mode {
+	case FixedReward:
+		return p.Amount
+	case DecayingReward:
+		halvings := (block - 1) / p.Halving
+		if halvings >= 63 {
+			return 0
+		}
+		return p.Amount >> halvings
+	default:
+		return 0
+	}
+}
+
+// fees returns the fees of one round's synthetic transactions.
+func (p RewardPolicy) fees(rng *rand.Rand) int {
+	if p.Mode != FeeReward {
+		return 0
+	}
+	total := 0
+	for i := 0; i < p.Txs; i++ {
+		total += 1 + rng.Intn(p.MaxFee)
+	}
+	return total
+}
+
+// Ledger is what a validator has earned and paid over the run. Net is its
+// utility from taking part: everything received minus everything paid or
+// slashed.
+type Ledger struct {
+	Wins          int
+	Rewards       int
+	Redistributed int
+	Payments      int
+	Slashed       int
+}
+
+// Net returns the validator's net utility.
+func (l Ledger) Net() int {
+	return l.Rewards + l.Redistributed - l.Payments - l.Slashed
+}
+
+// UtilitySummary describes how validators fared over a run.
+type UtilitySummary struct {
+	Validators int
+	// Ahead counts validators whose net utility is positive.
+	Ahead    int
+	Min, Max int
+	Mean     float64
+}
+
+// SummarizeUtility summarises the net utility in ledgers.
+func SummarizeUtility(ledgers map[string]Ledger) UtilitySummary {
+	summary := UtilitySummary{Validators: len(ledgers)}
+	total := 0
+	first := true
+	for _, ledger := range ledgers {
+		net := ledger.Net()
+		if net > 0 {
+			summary.Ahead++
+		}
+		if first || net < summary.Min {
+			summary.Min = net
+		}
+		if first || net > summary.Max {
+			summary.Max = net
+		}
+		first = false
+		total += net
+	}
+	if summary.Validators > 0 {
+		summary.Mean = float64(total) / float64(summary.Validators)
+	}
+	return summary
+}
+
+func (s UtilitySummary) String() string {
+	return fmt.Sprintf("%d of %d validators came out ahead; net utility min %d, mean %.1f, max %d",
+		s.Ahead, s.Validators, s.Min, s.Mean, s.Max)
+}
@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestParseRewardPolicy(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    RewardPolicy
+		wantErr bool
+	}{
+		{"none", RewardPolicy{}, false},
+		{"fixed:50", RewardPolicy{Mode: FixedReward, Amount: 50}, false},
+		{"decay:64:10", RewardPolicy{Mode: DecayingReward, Amount: 64, Halving: 10}, false},
+		{"fees:20:5", RewardPolicy{Mode: FeeReward, Txs: 20, MaxFee: 5}, false},
+		{"", RewardPolicy{}, true},
+		{"fixed", RewardPolicy{}, true},
+		{"fixed:0", RewardPolicy{}, true},
+		{"fixed:-5", RewardPolicy{}, true},
+		{"fixed:50:2", RewardPolicy{}, true},
+		{"decay:64", RewardPolicy{}, true},
+		{"decay:64:0", RewardPolicy{}, true},
+		{"fees:20:x", RewardPolicy{}, true},
+		{"tips:5", RewardPolicy{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			policy, err := ParseRewardPolicy(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if policy != tt.want {
+				t.Fatalf("parsed %+v, want %+v", policy, tt.want)
+			}
+			if policy.String() != tt.spec {
+				t.Fatalf("String() = %q, want %q", policy.String(), tt.spec)
+			}
+		})
+	}
+}
+
+func TestIssuance(t *testing.T) {
+	decay := RewardPolicy{Mode: DecayingReward, Amount: 64, Halving: 10}
+	tests := []struct {
+		name   string
+		policy RewardPolicy
+		block  int
+		want   int
+	}{
+		{"none", RewardPolicy{}, 1, 0},
+		{"fixed", RewardPolicy{Mode: FixedReward, Amount: 50}, 1000, 50},
+		{"fees mint nothing", RewardPolicy{Mode: FeeReward, Txs: 20, MaxFee: 5}, 1, 0},
+		{"decay: first block", decay, 1, 64},
+		{"decay: last block before halving", decay, 10, 64},
+		{"decay: first halving", decay, 11, 32},
+		{"decay: sixth halving", decay, 61, 1},
+		{"decay: rounds down to nothing", decay, 71, 0},
+		{"decay: 63 halvings", RewardPolicy{Mode: DecayingReward, Amount: 1 << 62, Halving: 1}, 64, 0},
+		{"decay: far past the last halving", RewardPolicy{Mode: DecayingReward, Amount: 1 << 62, Halving: 1}, 1000, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.issuance(tt.block); got != tt.want {
+				t.Fatalf("issuance(%d) = %d, want %d", tt.block, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFees(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RewardPolicy
+		min, max int
+	}{
+		{"no fees without a fee policy", RewardPolicy{Mode: FixedReward, Amount: 50}, 0, 0},
+		{"each transaction pays 1 to MaxFee", RewardPolicy{Mode: FeeReward, Txs: 10, MaxFee: 5}, 10, 50},
+		{"a MaxFee of 1 is exact", RewardPolicy{Mode: FeeReward, Txs: 3, MaxFee: 1}, 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			for i := 0; i < 1000; i++ {
+				if fees := tt.policy.fees(rng); fees < tt.min || fees > tt.max {
+					t.Fatalf("fees %d, want between %d and %d", fees, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+// TestFeePool checks that the fees of a round without a block carry over to
+// the next block, which mints them on top of its issuance while the price
+// its winner paid is burned.
+func TestFeePool(t *testing.T) {
+	policy := RewardPolicy{Mode: FeeReward, Txs: 10, MaxFee: 5}
+	rounds, registry, _, _ := testRounds(t, NewFirstPrice(), BidRules{}, Economics{Split: BurnAll, Reward: policy})
+	rng := rand.New(rand.NewSource(DeriveSeed(1, feeStream)))
+	want := policy.fees(rng) + policy.fees(rng)
+
+	if _, ok := rounds.Settle(); ok {
+		t.Fatal("round without bids produced a block")
+	}
+	rounds.Open()
+	registry.Register("winner", 1000)
+	if _, err := rounds.SubmitBid(BidRequest{Address: "winner", BPM: 70, Bid: 10}); err != nil {
+		t.Fatal(err)
+	}
+	result, ok := rounds.Settle()
+	if !ok {
+		t.Fatal("round with a bid produced no block")
+	}
+	block := result.Block
+	if block.Fees != want || block.Reward != want || block.Burned != 10 {
+		t.Fatalf("block has fees %d, reward %d and burned %d, want %d, %d and 10", block.Fees, block.Reward, block.Burned, want, want)
+	}
+	if rewards := registry.Ledgers()["winner"].Rewards; rewards != want {
+		t.Fatalf("winner rewarded %d, want %d", rewards, want)
+	}
+}
+
+func TestSummarizeUtility(t *testing.T) {
+	tests := []struct {
+		name    string
+		ledgers map[string]Ledger
+		want    UtilitySummary
+	}{
+		{"no validators", nil, UtilitySummary{}},
+		{"one validator behind", map[string]Ledger{"a": {Payments: 30}}, UtilitySummary{Validators: 1, Min: -30, Max: -30, Mean: -30}},
+		{"breaking even is not ahead", map[string]Ledger{"a": {Rewards: 30, Payments: 30}, "b": {Redistributed: 5}}, UtilitySummary{Validators: 2, Ahead: 1, Max: 5, Mean: 2.5}},
+		{"every ledger entry counts", map[string]Ledger{
+			"a": {Rewards: 100, Redistributed: 10, Payments: 40, Slashed: 10},
+			"b": {Payments: 20},
+			"c": {Slashed: 50},
+		}, UtilitySummary{Validators: 3, Ahead: 1, Min: -50, Max: 60, Mean: -10.0 / 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SummarizeUtility(tt.ledgers); got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
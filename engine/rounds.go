@@ -0,0 +1,441 @@
+package engine
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrInsufficientBalance is returned when a bid exceeds the bidder's balance.
+var ErrInsufficientBalance = errors.New("bid is more than your balance")
+
+// RoundResult describes a settled round.
+type RoundResult struct {
+	Round    int
+	OpenedAt time.Time
+	ClosedAt time.Time
+	Reserve  int
+	Winner   string
+	Price    int
+	Block    Block
+	Stats    RoundStats
+}
+
+// RoundManager collects bids and candidate blocks for the current round and
+// settles them through the configured selection mechanism. A round accepts
+// bids between Open and Close; Settle closes it if that has not happened yet.
+type RoundManager struct {
+	mu        sync.Mutex
+	chain     *Chain
+	registry  *Registry
+	mechanism SelectionMechanism
+	clock     Clock
+	rng       *rand.Rand
+	rules     BidRules
+	economics Economics
+	// feeRNG draws the synthetic transactions that fill feePool.
+	feeRNG     *rand.Rand
+	feePool    int
+	reserve    int
+	round      int
+	open       bool
+	settled    bool
+	openedAt   time.Time
+	closedAt   time.Time
+	candidates []Block
+	bids       []BidItem
+	// bidders holds the first bid or commitment of every validator in the
+	// round, to tell a retransmission from equivocation.
+	bidders  map[string]BidRequest
+	commits  map[string]*commitment
+	stats    RoundStats
+	evidence []Evidence
+	// seed and tickets are the sortition seed of the round and the proofs
+	// checked against it, under Sortition.
+	seed    []byte
+	tickets map[string]Ticket
+	// late holds bids that arrived while no round was open, under CarryLate,
+	// and lateBidders the validators that sent them.
+	late        []BidRequest
+	lateBidders map[string]bool
+}
+
+// NewRoundManager wires a round manager to its chain, registry and mechanism.
+// Candidate blocks are stamped with clock, bids are validated against rules,
+// payments are paid out according to economics and every lottery draw comes
+// from a single RNG stream seeded with seed. No round is open until Open is
+// called.
+func NewRoundManager(chain *Chain, registry *Registry, mechanism SelectionMechanism, clock Clock, rules BidRules, economics Economics, seed int64) *RoundManager {
+	return &RoundManager{
+		chain:     chain,
+		registry:  registry,
+		mechanism: mechanism,
+		clock:     clock,
+		rng:       rand.New(rand.NewSource(seed)),
+		rules:     rules,
+		economics: economics,
+		feeRNG:    rand.New(rand.NewSource(DeriveSeed(seed, feeStream))),
+		reserve:   rules.Reserve.Price,
+		settled:   true,
+		bidders:   make(map[string]BidRequest),
+		commits:   make(map[string]*commitment),
+		tickets:   make(map[string]Ticket),
+	}
+}
+
+// Round returns the number of the round currently collecting bids, or of the
+// last round if none is open.
+func (m *RoundManager) Round() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.round
+}
+
+// Reserve returns the reserve price of the round currently collecting bids,
+// or of the next one if none is open.
+func (m *RoundManager) Reserve() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reserve
+}
+
+// Open starts the next round and submits any bids carried over from the
+// previous one. Opening a round that is already open does nothing.
+func (m *RoundManager) Open() RoundInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.open {
+		return RoundInfo{ID: m.round, OpenedAt: m.openedAt, Reserve: m.reserve, Seed: m.seed}
+	}
+	m.round++
+	m.open = true
+	m.settled = false
+	m.openedAt = m.clock.Now()
+	m.closedAt = time.Time{}
+	if usesSortition(m.mechanism) {
+		m.seed = SortitionSeed(m.chain.Last().Hash, m.round)
+	}
+
+	late := m.late
+	m.late, m.lateBidders = nil, nil
+	for _, req := range late {
+		if err := m.admit(req); err != nil {
+			m.stats.reject(err)
+			continue
+		}
+		m.stats.Accepted++
+		m.stats.Carried++
+	}
+	return RoundInfo{ID: m.round, OpenedAt: m.openedAt, Reserve: m.reserve, Seed: m.seed}
+}
+
+// Seed returns the sortition seed of the current round, or nil when the
+// mechanism is not Sortition.
+func (m *RoundManager) Seed() []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.seed
+}
+
+// Close stops the current round from accepting bids. Bids that arrive until
+// the next Open are handled by the late bid policy.
+func (m *RoundManager) Close() RoundInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.close()
+	return RoundInfo{ID: m.round, OpenedAt: m.openedAt, ClosedAt: m.closedAt, Reserve: m.reserve, Seed: m.seed}
+}
+
+func (m *RoundManager) close() {
+	if m.open {
+		m.open = false
+		m.closedAt = m.clock.Now()
+	}
+}
+
+// SubmitBid authenticates and validates a validator's bid, escrows it and
+// queues its candidate block for the current round. It returns the round
+// the bid counts toward, which is the next one for a carried late bid.
+// Rejections are counted in the round's stats.
+func (m *RoundManager) SubmitBid(req BidRequest) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if req.Kind.staking() {
+		return m.round, m.stake(req)
+	}
+	if req.Kind == RevealBid {
+		if err := m.reveal(req); err != nil {
+			m.stats.reject(err)
+			return m.round, err
+		}
+		m.stats.Revealed++
+		return m.round, nil
+	}
+	if !m.open {
+		if m.rules.Late != CarryLate {
+			m.stats.reject(ErrRoundClosed)
+			return m.round, ErrRoundClosed
+		}
+		if err := m.carry(req); err != nil {
+			m.stats.reject(err)
+			return m.round, err
+		}
+		return m.round + 1, nil
+	}
+	// Commitments bind their round, so even unsigned ones must name it.
+	if (req.Signature != nil || req.Kind == CommitBid) && req.Round != m.round {
+		m.stats.reject(ErrWrongRound)
+		return m.round, ErrWrongRound
+	}
+	if err := m.registry.Authorize(req); err != nil {
+		m.stats.reject(err)
+		return m.round, err
+	}
+	if err := m.admit(req); err != nil {
+		m.stats.reject(err)
+		return m.round, err
+	}
+	m.stats.Accepted++
+	return m.round, nil
+}
+
+// Reject counts a bid that was turned away before it reached SubmitBid, such
+// as one that could not be parsed.
+func (m *RoundManager) Reject(err error) {
+	m.mu.Lock()
+	m.stats.reject(err)
+	m.mu.Unlock()
+}
+
+// carry authorizes a late bid and holds it for the next round. A validator
+// may have one bid waiting, so the queue never outgrows the registry.
+func (m *RoundManager) carry(req BidRequest) error {
+	if err := m.registry.Authorize(req); err != nil {
+		return err
+	}
+	if m.lateBidders[req.Address] {
+		return ErrLateBidWaiting
+	}
+	if m.lateBidders == nil {
+		m.lateBidders = make(map[string]bool)
+	}
+	m.lateBidders[req.Address] = true
+	m.late = append(m.late, req)
+	return nil
+}
+
+// admit adds an authorized bid, or under a SealedMechanism a commitment, to
+// the open round. Carried bids are admitted even though they were signed for
+// the previous round.
+func (m *RoundManager) admit(req BidRequest) error {
+	sealed, isSealed := m.mechanism.(SealedMechanism)
+	switch {
+	case isSealed && req.Kind == CommitBid:
+		return m.commit(req, sealed)
+	case isSealed:
+		return ErrCommitRequired
+	case req.Kind != PlainBid:
+		return ErrNotSealed
+	}
+
+	address, bpm, bid := req.Address, req.BPM, req.Bid
+	if err := m.registry.Eligible(address, m.round); err != nil {
+		return err
+	}
+	if first, ok := m.bidders[address]; ok {
+		return m.rebid(first, req, first.BPM == bpm && first.Bid == bid)
+	}
+	if err := m.checkBlock(req); err != nil {
+		return err
+	}
+	if err := m.checkBid(bid); err != nil {
+		return err
+	}
+	if err := m.checkIncrement(bid); err != nil {
+		return err
+	}
+	if err := m.checkProof(req); err != nil {
+		return err
+	}
+	if !m.registry.Escrow(address, bid) {
+		return ErrInsufficientBalance
+	}
+	m.bidders[address] = req
+	m.addBid(address, bpm, bid)
+	return nil
+}
+
+// rebid handles a second bid or commitment from a validator in one round:
+// a retransmission of the first is a duplicate, anything else equivocates.
+func (m *RoundManager) rebid(first, req BidRequest, same bool) error {
+	if !same && m.slash(req.Address, Equivocation, proof(first), proof(req)) {
+		return ErrEquivocation
+	}
+	return ErrDuplicateBid
+}
+
+// checkBlock rejects, and slashes, a bid whose candidate block is invalid.
+func (m *RoundManager) checkBlock(req BidRequest) error {
+	if req.BPM > 0 {
+		return nil
+	}
+	m.slash(req.Address, InvalidBlock, proof(req))
+	return ErrInvalidBlock
+}
+
+// checkBid validates a bid amount against the rules and the round's reserve.
+func (m *RoundManager) checkBid(bid int) error {
+	if err := m.rules.Check(bid); err != nil {
+		return err
+	}
+	if bid < m.reserve {
+		return ErrBelowReserve
+	}
+	return nil
+}
+
+// checkIncrement rejects an open bid that does not beat the round's best bid
+// by at least the minimum increment.
+func (m *RoundManager) checkIncrement(bid int) error {
+	if m.rules.MinIncrement <= 0 {
+		return nil
+	}
+	for _, bidItem := range m.bids {
+		if bid < bidItem.Bid+m.rules.MinIncrement {
+			return ErrBidIncrement
+		}
+	}
+	return nil
+}
+
+// addBid records an escrowed bid and its candidate block.
+func (m *RoundManager) addBid(address string, bpm, bid int) {
+	m.bids = append(m.bids, BidItem{NodeAddress: address, Bid: bid})
+
+	// only generate a block when a valid bid is received
+	oldLastIndex := m.chain.Last()
+	newBlock := GenerateBlock(oldLastIndex, bpm, address, m.clock.Now())
+	if IsBlockValid(newBlock, oldLastIndex) {
+		m.candidates = append(m.candidates, newBlock)
+	}
+}
+
+// Settle closes the current round if it is still open, runs the selection
+// mechanism, settles every escrowed bid and appends the winner's block to
+// the chain. The second return value is false when no block was produced.
+func (m *RoundManager) Settle() (RoundResult, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.close()
+	revenue := 0
+	m.dropIneligible()
+	round := Round{
+		Bids:       m.bids,
+		Candidates: m.candidates,
+		Balances:   make(map[string]int),
+		Reserve:    m.reserve,
+		Weights:    m.weights(),
+		Seed:       m.seed,
+		Tickets:    m.tickets,
+	}
+	for _, bidItem := range m.bids {
+		if balance, ok := m.registry.Balance(bidItem.NodeAddress); ok {
+			round.Balances[bidItem.NodeAddress] = balance
+		}
+	}
+
+	outcome := m.mechanism.Select(round, m.rng)
+
+	for addr := range escrowedBids(m.bids) {
+		revenue += m.registry.Release(addr, outcome.Payments[addr])
+	}
+	// Sealed bids escrow a deposit at commit time: revealers got it back
+	// above, everyone else forfeits it. They are slashed in address order so
+	// that the evidence is the same in every run with the same seed.
+	if sealed, ok := m.mechanism.(SealedMechanism); ok {
+		var unrevealed []string
+		for addr, c := range m.commits {
+			if !c.revealed {
+				unrevealed = append(unrevealed, addr)
+			}
+		}
+		sort.Strings(unrevealed)
+		for _, addr := range unrevealed {
+			revenue += m.registry.Release(addr, sealed.Forfeit())
+			m.stats.Forfeited++
+			m.slash(addr, NonReveal, proof(m.commits[addr].req))
+		}
+	}
+	m.feePool += m.economics.Reward.fees(m.feeRNG)
+	reward, fees := 0, 0
+	if outcome.Winner != "" {
+		fees, m.feePool = m.feePool, 0
+		reward = m.economics.Reward.issuance(m.chain.Len()) + fees
+	}
+	paid := m.economics.distribute(m.registry, m.bids, committeeOf(outcome), revenue, reward)
+	m.stats.Revenue += revenue
+	m.stats.Burned += paid.Burned
+	m.stats.Minted += paid.Minted
+	m.registry.ReleaseUnbonded(m.round)
+	reserve := m.reserve
+	m.reserve = m.rules.Reserve.Next(m.reserve, len(m.bids))
+	m.candidates = nil
+	m.bids = nil
+	m.bidders = make(map[string]BidRequest)
+	m.commits = make(map[string]*commitment)
+	m.tickets = make(map[string]Ticket)
+	m.settled = true
+	result := RoundResult{Round: m.round, OpenedAt: m.openedAt, ClosedAt: m.closedAt, Reserve: reserve, Stats: m.stats}
+	m.stats = RoundStats{}
+
+	if outcome.Winner == "" {
+		return result, false
+	}
+
+	selectedBlock := selectBlockForWinner(round.Candidates, outcome.Winner)
+	selectedBlock.Validator = outcome.Winner
+	selectedBlock.Committee = outcome.Committee
+	selectedBlock.Transfer = outcome.Price
+	selectedBlock.Round = m.round
+	selectedBlock.BidsOpened = m.openedAt.String()
+	selectedBlock.BidsClosed = m.closedAt.String()
+	selectedBlock.Reserve = reserve
+	selectedBlock.Reward = paid.Minted
+	selectedBlock.Fees = fees
+	selectedBlock.Burned = paid.Burned
+	selectedBlock.Supply = m.registry.Supply()
+	selectedBlock.Evidence, m.evidence = m.evidence, nil
+	selectedBlock.Sortition = outcome.Sortition
+	m.chain.Append(selectedBlock)
+
+	result.Winner, result.Price, result.Block = outcome.Winner, outcome.Price, selectedBlock
+	return result, true
+}
+
+// dropIneligible refunds and removes the bids of validators jailed or ejected
+// since they bid, so that they cannot win the round.
+func (m *RoundManager) dropIneligible() {
+	var bids []BidItem
+	for _, bidItem := range m.bids {
+		if m.registry.Eligible(bidItem.NodeAddress, m.round) != nil {
+			m.registry.Release(bidItem.NodeAddress, 0)
+			continue
+		}
+		bids = append(bids, bidItem)
+	}
+	if len(bids) == len(m.bids) {
+		return
+	}
+	var candidates []Block
+	for _, block := range m.candidates {
+		if m.registry.Eligible(block.Proposer, m.round) == nil {
+			candidates = append(candidates, block)
+		}
+	}
+	m.bids, m.candidates = bids, candidates
+}
@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+)
+
+var (
+	// ErrCommitRequired is returned for plain bids in a sealed-bid round.
+	ErrCommitRequired = errors.New("sealed-bid rounds only take commitments")
+	// ErrNotSealed is returned for commitments and reveals in a round that
+	// takes plain bids.
+	ErrNotSealed = errors.New("round does not take sealed bids")
+	// ErrRevealClosed is returned for reveals outside the reveal phase.
+	ErrRevealClosed = errors.New("reveals are only accepted between close and settlement")
+	// ErrNoCommitment is returned for a reveal without a matching commit.
+	ErrNoCommitment = errors.New("no commitment to reveal in this round")
+	// ErrCommitmentMismatch is returned when a revealed bid and salt do not
+	// hash to the commitment.
+	ErrCommitmentMismatch = errors.New("revealed bid does not match the commitment")
+)
+
+// SealedMechanism is a selection mechanism that runs over sealed bids. While
+// a round is open validators only commit to H(address || round || bid ||
+// salt) and escrow the forfeit as a deposit; after it closes they reveal bid
+// and salt. Only
+// revealed bids reach Select, and validators that never reveal lose their
+// deposit.
+type SealedMechanism interface {
+	SelectionMechanism
+	Forfeit() int
+}
+
+// CommitReveal runs another mechanism as a sealed-bid auction.
+type CommitReveal struct {
+	inner   SelectionMechanism
+	forfeit int
+}
+
+// NewCommitReveal wraps inner in a commit-reveal round; validators that
+// commit but do not reveal pay forfeit.
+func NewCommitReveal(inner SelectionMechanism, forfeit int) *CommitReveal {
+	if forfeit < 0 {
+		forfeit = 0
+	}
+	return &CommitReveal{inner: inner, forfeit: forfeit}
+}
+
+func (c *CommitReveal) Name() string { return "sealed-" + c.inner.Name() }
+
+// Forfeit returns what a validator pays for not revealing its commitment.
+func (c *CommitReveal) Forfeit() int { return c.forfeit }
+
+// Select runs the wrapped mechanism over the revealed bids.
+func (c *CommitReveal) Select(round Round, rng *rand.Rand) Outcome {
+	return c.inner.Select(round, rng)
+}
+
+// commitment is a sealed bid waiting to be revealed.
+type commitment struct {
+	req      BidRequest
+	bpm      int
+	digest   []byte
+	revealed bool
+}
+
+// commit records an authorized sealed bid for the open round and escrows the
+// forfeit.
+func (m *RoundManager) commit(req BidRequest, sealed SealedMechanism) error {
+	if len(req.Commitment) == 0 {
+		return ErrMalformedBid
+	}
+	if err := m.registry.Eligible(req.Address, m.round); err != nil {
+		return err
+	}
+	if first, ok := m.bidders[req.Address]; ok {
+		return m.rebid(first, req, first.BPM == req.BPM && bytes.Equal(first.Commitment, req.Commitment))
+	}
+	if err := m.checkBlock(req); err != nil {
+		return err
+	}
+	if err := m.checkProof(req); err != nil {
+		return err
+	}
+	if !m.registry.Escrow(req.Address, sealed.Forfeit()) {
+		return ErrInsufficientBalance
+	}
+	m.bidders[req.Address] = req
+	m.commits[req.Address] = &commitment{req: req, bpm: req.BPM, digest: req.Commitment}
+	return nil
+}
+
+// reveal opens a commitment between Close and Settle. The revealed bid is
+// validated and escrowed on top of the deposit like a plain bid.
+func (m *RoundManager) reveal(req BidRequest) error {
+	if _, ok := m.mechanism.(SealedMechanism); !ok {
+		return ErrNotSealed
+	}
+	if m.open || m.settled {
+		return ErrRevealClosed
+	}
+	if req.Signature != nil && req.Round != m.round {
+		return ErrWrongRound
+	}
+	c, ok := m.commits[req.Address]
+	if !ok || c.revealed {
+		return ErrNoCommitment
+	}
+	if err := m.registry.Authorize(req); err != nil {
+		return err
+	}
+	if !bytes.Equal(Commitment(c.req.Address, c.req.Round, req.Bid, req.Salt), c.digest) {
+		return ErrCommitmentMismatch
+	}
+	if err := m.checkBid(req.Bid); err != nil {
+		return err
+	}
+	if !m.registry.Escrow(req.Address, req.Bid) {
+		return ErrInsufficientBalance
+	}
+	c.revealed = true
+	m.addBid(req.Address, c.bpm, req.Bid)
+	return nil
+}
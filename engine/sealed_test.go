@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+// testSealedRounds returns testRounds under a sealed Vickrey lottery with
+// the given forfeit.
+func testSealedRounds(t *testing.T, forfeit int) (*RoundManager, *Registry, func(nonce uint64, req BidRequest) BidRequest, string) {
+	t.Helper()
+	rounds, registry, key, address := testRounds(t, NewCommitReveal(NewVickreyLottery(), forfeit), BidRules{}, Economics{})
+	sign := func(nonce uint64, req BidRequest) BidRequest {
+		return signed(key, address, 1, nonce, req)
+	}
+	return rounds, registry, sign, address
+}
+
+func TestRevealCommitment(t *testing.T) {
+	salt := []byte("salt")
+	tests := []struct {
+		name string
+		// commit returns what the validator at address commits to in round 1.
+		commit func(address string) []byte
+		bid    int
+		salt   []byte
+		want   error
+	}{
+		{"matching reveal", func(a string) []byte { return Commitment(a, 1, 40, salt) }, 40, salt, nil},
+		{"wrong bid", func(a string) []byte { return Commitment(a, 1, 40, salt) }, 41, salt, ErrCommitmentMismatch},
+		{"wrong salt", func(a string) []byte { return Commitment(a, 1, 40, salt) }, 40, []byte("pepper"), ErrCommitmentMismatch},
+		{"no salt", func(a string) []byte { return Commitment(a, 1, 40, salt) }, 40, nil, ErrCommitmentMismatch},
+		{"another address's commitment", func(string) []byte { return Commitment("other", 1, 40, salt) }, 40, salt, ErrCommitmentMismatch},
+		{"another round's commitment", func(a string) []byte { return Commitment(a, 2, 40, salt) }, 40, salt, ErrCommitmentMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rounds, _, sign, address := testSealedRounds(t, 5)
+			if _, err := rounds.SubmitBid(sign(1, BidRequest{Kind: CommitBid, BPM: 70, Commitment: tt.commit(address)})); err != nil {
+				t.Fatalf("commit: %v", err)
+			}
+			rounds.Close()
+			_, err := rounds.SubmitBid(sign(2, BidRequest{Kind: RevealBid, Bid: tt.bid, Salt: tt.salt}))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSealedPhases(t *testing.T) {
+	salt := []byte("salt")
+	rounds, _, sign, address := testSealedRounds(t, 5)
+	reveal := BidRequest{Kind: RevealBid, Bid: 40, Salt: salt}
+
+	steps := []struct {
+		name string
+		// then moves the round on after the request.
+		then func()
+		req  BidRequest
+		want error
+	}{
+		{"plain bid", nil, sign(1, BidRequest{BPM: 70, Bid: 40}), ErrCommitRequired},
+		{"reveal while open", nil, sign(2, reveal), ErrRevealClosed},
+		{"commit", func() { rounds.Close() }, sign(3, BidRequest{Kind: CommitBid, BPM: 70, Commitment: Commitment(address, 1, 40, salt)}), nil},
+		{"reveal", nil, sign(4, reveal), nil},
+		{"reveal twice", func() { rounds.Settle() }, sign(5, reveal), ErrNoCommitment},
+		{"reveal after settlement", nil, sign(6, reveal), ErrRevealClosed},
+	}
+	for _, step := range steps {
+		if _, err := rounds.SubmitBid(step.req); !errors.Is(err, step.want) {
+			t.Fatalf("%s: error %v, want %v", step.name, err, step.want)
+		}
+		if step.then != nil {
+			step.then()
+		}
+	}
+
+	plain, _, key, address := testRounds(t, NewVickreyLottery(), BidRules{}, Economics{})
+	commit := signed(key, address, 1, 1, BidRequest{Kind: CommitBid, BPM: 70, Commitment: Commitment(address, 1, 40, salt)})
+	if _, err := plain.SubmitBid(commit); !errors.Is(err, ErrNotSealed) {
+		t.Fatalf("commit to an open-bid round: error %v, want %v", err, ErrNotSealed)
+	}
+}
+
+func TestForfeit(t *testing.T) {
+	const forfeit = 30
+	salt := []byte("salt")
+	tests := []struct {
+		name      string
+		reveal    bool
+		revealed  int
+		forfeited int
+	}{
+		{"revealed", true, 1, 0},
+		{"not revealed", false, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rounds, registry, sign, address := testSealedRounds(t, forfeit)
+			if _, err := rounds.SubmitBid(sign(1, BidRequest{Kind: CommitBid, BPM: 70, Commitment: Commitment(address, 1, 40, salt)})); err != nil {
+				t.Fatalf("commit: %v", err)
+			}
+			if balance, _ := registry.Balance(address); balance != 1000-forfeit {
+				t.Fatalf("balance %d after commit, want the forfeit escrowed", balance)
+			}
+			rounds.Close()
+			if tt.reveal {
+				if _, err := rounds.SubmitBid(sign(2, BidRequest{Kind: RevealBid, Bid: 40, Salt: salt})); err != nil {
+					t.Fatalf("reveal: %v", err)
+				}
+			}
+			result, _ := rounds.Settle()
+			if result.Stats.Revealed != tt.revealed || result.Stats.Forfeited != tt.forfeited {
+				t.Fatalf("%d revealed and %d forfeited, want %d and %d", result.Stats.Revealed, result.Stats.Forfeited, tt.revealed, tt.forfeited)
+			}
+			ledger := registry.Ledgers()[address]
+			balance, _ := registry.Balance(address)
+			if balance != 1000+ledger.Net() {
+				t.Fatalf("balance %d, want 1000 plus net %d: escrow not released", balance, ledger.Net())
+			}
+			if !tt.reveal && ledger.Payments != forfeit {
+				t.Fatalf("paid %d for not revealing, want %d", ledger.Payments, forfeit)
+			}
+		})
+	}
+}
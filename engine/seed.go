@@ -0,0 +1,11 @@
+package engine
+
+// DeriveSeed maps a master seed and a stream number to an independent seed
+// using the SplitMix64 finaliser, so that sub-streams derived from one master
+// seed do not overlap and are identical on every platform.
+func DeriveSeed(master int64, stream int64) int64 {
+	z := uint64(master) + uint64(stream+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
@@ -0,0 +1,414 @@
+package engine
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// Config selects the mechanism and metrics a server runs with.
+type Config struct {
+	Port      string
+	Mechanism SelectionMechanism
+	Gini      GiniFunc
+	// Committee is the committee policy Mechanism was wrapped in, if any,
+	// for the logs and the export.
+	Committee CommitteePolicy
+	// Weighting is the weighting policy Mechanism was wrapped in, if any.
+	Weighting WeightingPolicy
+	// SortitionSize is the expected number of validators the sortition
+	// mechanism selects per round.
+	SortitionSize float64
+
+	// RoundInterval is the bidding window: how long a round stays open.
+	RoundInterval time.Duration
+	// SettlementDelay is the pause between a round closing and being
+	// settled. The next round opens once the previous one has settled. For a
+	// SealedMechanism it is the reveal window.
+	SettlementDelay time.Duration
+	// ChainBroadcastInterval is how often the full chain is pushed to every
+	// connection. Zero disables the broadcast.
+	ChainBroadcastInterval time.Duration
+	// ExportPath is where the chain is exported after every round, with the
+	// blocks as JSON lines next to it at ChainPath(ExportPath).
+	ExportPath string
+	// Seed drives every lottery draw and is recorded in the export.
+	Seed int64
+	// AnnouncementQueue is how many round events may wait for a slow
+	// validator before SlowConsumerPolicy applies.
+	AnnouncementQueue  int
+	SlowConsumerPolicy OverflowPolicy
+	// RequireSignatures turns away validators that do not register an
+	// Ed25519 public key, which includes every legacy text client.
+	RequireSignatures bool
+	// Bids are the limits every bid is validated against.
+	Bids BidRules
+	// Stakes decides the starting balance of every new validator.
+	Stakes StakePolicy
+	// Economics decides where payments go and what winners are minted. The
+	// zero value burns every payment.
+	Economics Economics
+	// Inactivity decides what happens to accounts whose validator has not
+	// been connected for a number of rounds.
+	Inactivity InactivityPolicy
+}
+
+// DefaultFaucet is the stake every validator starts with when the server runs
+// without a genesis file.
+const DefaultFaucet = 1000
+
+// DefaultRevealWindow is the settlement delay of sealed-bid rounds when none
+// is configured.
+const DefaultRevealWindow = 10 * time.Second
+
+// Server accepts validator connections over TCP and runs the round loop.
+type Server struct {
+	cfg           Config
+	chain         *Chain
+	registry      *Registry
+	rounds        *RoundManager
+	announcements *Broadcaster
+
+	// conns holds the live connection of every validator so that a resume
+	// from a new connection can take over from a stale one.
+	mu    sync.Mutex
+	conns map[string]net.Conn
+}
+
+// NewServer creates a server with a fresh chain and validator registry.
+func NewServer(cfg Config) *Server {
+	if cfg.Gini == nil {
+		cfg.Gini = SortedGini
+	}
+	if cfg.RoundInterval <= 0 {
+		cfg.RoundInterval = 60 * time.Second
+	}
+	if cfg.ExportPath == "" {
+		cfg.ExportPath = "blockchain.xlsx"
+	}
+	if cfg.AnnouncementQueue <= 0 {
+		cfg.AnnouncementQueue = 16
+	}
+	if _, sealed := cfg.Mechanism.(SealedMechanism); sealed && cfg.SettlementDelay <= 0 {
+		cfg.SettlementDelay = DefaultRevealWindow
+	}
+	if cfg.Economics.Split == (PriceSplit{}) {
+		cfg.Economics.Split = BurnAll
+	}
+	if cfg.Stakes.Stakes == nil && cfg.Stakes.Faucet <= 0 {
+		cfg.Stakes.Faucet = DefaultFaucet
+	}
+
+	chain := NewChain(time.Now())
+	registry := NewRegistry()
+	return &Server{
+		cfg:           cfg,
+		chain:         chain,
+		registry:      registry,
+		rounds:        NewRoundManager(chain, registry, cfg.Mechanism, wallClock{}, cfg.Bids, cfg.Economics, cfg.Seed),
+		announcements: NewBroadcaster(cfg.AnnouncementQueue, cfg.SlowConsumerPolicy),
+		conns:         make(map[string]net.Conn),
+	}
+}
+
+// ListenAndServe listens on the configured port, starts the round loop and
+// serves validator connections until accepting fails.
+func (s *Server) ListenAndServe() error {
+	spew.Dump(s.chain.Genesis())
+
+	server, err := net.Listen("tcp", ":"+s.cfg.Port)
+	if err != nil {
+		return err
+	}
+	log.Println("TCP Server Listening on port :", s.cfg.Port)
+	log.Printf("Mechanism %s, seed %d", s.cfg.Mechanism.Name(), s.cfg.Seed)
+	if s.cfg.Committee.Mode != NoCommittee {
+		log.Printf("Committee: %s", s.cfg.Committee)
+	}
+	if len(s.cfg.Weighting) > 0 {
+		log.Printf("Weighting: %s", s.cfg.Weighting)
+	}
+	if usesSortition(s.cfg.Mechanism) {
+		log.Printf("Sortition: %v validators selected per round on average", s.cfg.SortitionSize)
+	}
+	log.Printf("Bidding window %v, settlement delay %v, late bids: %s", s.cfg.RoundInterval, s.cfg.SettlementDelay, s.cfg.Bids.Late)
+	log.Printf("Reserve price: %s", s.cfg.Bids.Reserve)
+	log.Printf("Price split: %s, reward %s", s.cfg.Economics.Split, s.cfg.Economics.Reward)
+	log.Printf("Stake policy: %s", s.cfg.Stakes)
+	if len(s.cfg.Economics.Slashing) > 0 {
+		log.Printf("Slashing: %s", s.cfg.Economics.Slashing)
+	}
+	if s.cfg.Economics.Bonding.Enabled {
+		log.Printf("Selection by bonded stake, unbonding takes %d rounds", s.cfg.Economics.Bonding.UnbondingRounds)
+	}
+	log.Printf("Inactive validators: %s", s.cfg.Inactivity)
+	defer server.Close()
+
+	go s.runRounds()
+
+	for {
+		conn, err := server.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) runRounds() {
+	for {
+		info := s.rounds.Open()
+		info.ClosesAt = info.OpenedAt.Add(s.cfg.RoundInterval)
+		s.announce(RoundEvent{Phase: PhaseOpen, Round: info})
+
+		time.Sleep(s.cfg.RoundInterval)
+		info = s.rounds.Close()
+		s.announce(RoundEvent{Phase: PhaseClosed, Round: info})
+
+		if s.cfg.SettlementDelay > 0 {
+			time.Sleep(s.cfg.SettlementDelay)
+		}
+		result, _ := s.rounds.Settle()
+		log.Printf("Round %d: %s", result.Round, result.Stats)
+		s.announce(RoundEvent{Phase: PhaseSettled, Round: info, Result: result})
+
+		if affected := s.registry.ApplyInactivity(result.Round, s.cfg.Inactivity); len(affected) > 0 {
+			log.Printf("%d inactive validators affected by policy %s", len(affected), s.cfg.Inactivity)
+		}
+		s.printGiniCoefficient()
+		blocks := s.chain.Blocks()
+		if err := ExportBlockchainToExcel(blocks, s.runInfo(), s.registry.Ledgers(), s.cfg.ExportPath); err != nil {
+			log.Printf("Error saving to excel: %v", err)
+		}
+		if err := ExportChain(blocks, ChainPath(s.cfg.ExportPath)); err != nil {
+			log.Printf("Error saving the chain: %v", err)
+		}
+	}
+}
+
+func (s *Server) announce(event RoundEvent) {
+	if _, overflowed := s.announcements.Publish(event); overflowed > 0 {
+		log.Printf("%d slow validators overflowed their announcement queue (policy %s)", overflowed, s.cfg.SlowConsumerPolicy)
+	}
+}
+
+func (s *Server) runInfo() RunInfo {
+	return RunInfo{Mechanism: s.cfg.Mechanism.Name(), Seed: s.cfg.Seed, Committee: s.cfg.Committee.String(), Weighting: s.cfg.Weighting.String(), Reserve: s.cfg.Bids.Reserve.String(), Economics: s.cfg.Economics}
+}
+
+func (s *Server) printGiniCoefficient() {
+	gini := s.cfg.Gini(s.registry.ValidatorBalances())
+	fmt.Println("Gini Coefficient: ", gini)
+	if s.registry.HasDelegators() {
+		fmt.Println("Gini Coefficient (all holders): ", s.cfg.Gini(s.registry.Balances()))
+	}
+	fmt.Println("Total Supply: ", s.registry.Supply())
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	// Legacy clients answer the balance prompt; JSON-lines clients send a
+	// hello message instead.
+	io.WriteString(conn, "Enter token balance:")
+	scanner := bufio.NewScanner(conn)
+	if !scanner.Scan() {
+		return
+	}
+	var sess session
+	if first := scanner.Text(); isHello(first) {
+		challenge, err := randomChallenge()
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		sess = newJSONSession(conn, scanner, challenge)
+	} else {
+		sess = newTextSession(conn, scanner, first)
+	}
+
+	reg, err := sess.register()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	address, err := s.register(reg)
+	if err != nil {
+		log.Println(err)
+		sess.registrationFailed(rejectReason(err))
+		return
+	}
+	if reg.Delegator && !reg.Resume {
+		s.registry.SetDelegator(address)
+	}
+	s.attach(address, conn)
+	defer s.detach(address, conn)
+	balance, _ := s.registry.Balance(address)
+	sess.registered(address, balance, s.rounds.Round(), s.rounds.Reserve(), s.rounds.Seed(), s.registry.Nonce(address), reg.Resume)
+
+	// A validator that stops reading leaves the goroutine below blocked on a
+	// write, so falling behind closes the connection rather than waiting for
+	// it to notice its queue was closed.
+	sub := s.announcements.SubscribeFunc(func() { conn.Close() })
+	defer sub.Close()
+	go func() {
+		for event := range sub.C {
+			switch event.Phase {
+			case PhaseOpen:
+				sess.roundOpened(event.Round)
+			case PhaseClosed:
+				sess.roundClosed(event.Round)
+			case PhaseSettled:
+				sess.roundResult(event.Result)
+				balance, _ := s.registry.Balance(address)
+				sess.balance(balance)
+			}
+		}
+		// The queue is closed either because this handler is returning or
+		// because the validator fell too far behind and was disconnected.
+		conn.Close()
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	if s.cfg.ChainBroadcastInterval > 0 {
+		go s.broadcastChain(sess, done)
+	}
+
+	for {
+		req, err := sess.nextBid()
+		if errors.Is(err, ErrMalformedBid) {
+			s.rounds.Reject(err)
+			sess.bidRejected(rejectReason(err))
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.Println(err)
+			}
+			return
+		}
+
+		req.Address = address
+		round, err := s.rounds.SubmitBid(req)
+		if req.Kind.staking() {
+			if err != nil {
+				sess.bidRejected(rejectReason(err))
+				continue
+			}
+			balance, _ := s.registry.Balance(address)
+			bonded, unbonding := s.registry.Stake(address)
+			sess.staked(balance, bonded, unbonding, s.registry.Delegations(address))
+			continue
+		}
+		if err != nil {
+			log.Println(err)
+			sess.bidRejected(rejectReason(err))
+			continue
+		}
+		sess.bidAccepted(req.BPM, req.Bid, round)
+	}
+}
+
+// register adds a connecting validator to the registry with the stake the
+// StakePolicy grants it. Validators with a public key get the address derived
+// from it; legacy validators get a random one unless the server requires
+// signatures. A resuming validator is reattached to its existing account
+// instead.
+func (s *Server) register(reg registration) (string, error) {
+	if reg.Resume {
+		if reg.PublicKey == nil || !VerifyResume(reg.PublicKey, reg.Challenge, reg.Signature) {
+			return "", ErrBadResume
+		}
+		return s.registry.Resume(reg.PublicKey)
+	}
+	if reg.PublicKey != nil {
+		stake, err := s.cfg.Stakes.Stake(AddressFromKey(reg.PublicKey))
+		if err != nil {
+			return "", err
+		}
+		return s.registry.RegisterKey(reg.PublicKey, stake)
+	}
+	if s.cfg.RequireSignatures {
+		return "", ErrSignatureRequired
+	}
+	address, err := randomAddress()
+	if err != nil {
+		return "", err
+	}
+	stake, err := s.cfg.Stakes.Stake(address)
+	if err != nil {
+		return "", err
+	}
+	s.registry.Register(address, stake)
+	return address, nil
+}
+
+// attach records conn as the validator's live connection. A connection left
+// over from before a resume is closed so that its handler exits and only one
+// connection bids for the account.
+func (s *Server) attach(address string, conn net.Conn) {
+	s.mu.Lock()
+	old := s.conns[address]
+	s.conns[address] = conn
+	s.registry.SetConnected(address, true)
+	s.mu.Unlock()
+	if old != nil {
+		log.Printf("validator %s resumed from a new connection", address)
+		old.Close()
+	}
+}
+
+// detach marks the validator as disconnected unless another connection has
+// already taken over its account.
+func (s *Server) detach(address string, conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns[address] != conn {
+		return
+	}
+	delete(s.conns, address)
+	s.registry.SetConnected(address, false)
+}
+
+// randomChallenge returns the nonce a resuming validator must sign.
+func randomChallenge() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf[:]), nil
+}
+
+// randomAddress returns a fresh address for a legacy validator. Unlike a hash
+// of the connection time it cannot collide when validators connect at once.
+func randomAddress() (string, error) {
+	var buf [32]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return CalculateHash(hex.EncodeToString(buf[:])), nil
+}
+
+func (s *Server) broadcastChain(sess session, done <-chan struct{}) {
+	ticker := time.NewTicker(s.cfg.ChainBroadcastInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			sess.chain(s.chain.Blocks())
+		}
+	}
+}
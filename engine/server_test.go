@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestServerDisconnectsStalledValidator checks that a validator that stops
+// reading is disconnected once its announcement queue overflows, even though
+// the goroutine announcing to it is stuck writing to the connection.
+func TestServerDisconnectsStalledValidator(t *testing.T) {
+	s := NewServer(Config{
+		Mechanism:         NewVickreyLottery(),
+		AnnouncementQueue: 2,
+		Stakes:            StakePolicy{Faucet: 1000},
+		ExportPath:        filepath.Join(t.TempDir(), "blockchain.xlsx"),
+	})
+	server, client := net.Pipe()
+	defer client.Close()
+	done := make(chan struct{})
+	go func() {
+		s.handleConn(server)
+		close(done)
+	}()
+
+	// Register over the text protocol, then never read again. net.Pipe has
+	// no buffer, so the next announcement blocks its writer for good.
+	reader := bufio.NewReader(client)
+	if _, err := io.ReadFull(reader, make([]byte, len("Enter token balance:"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(client, "1000\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(reader, make([]byte, len("\nEnter a new BPM:"))); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); s.announcements.Len() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("validator never subscribed to announcements")
+		}
+	}
+
+	// One event blocks the writer, two fill the queue and the fourth
+	// overflows it.
+	for id := 1; id <= 4; id++ {
+		s.announce(roundEvent(id))
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled validator still connected after its queue overflowed")
+	}
+	if n := s.announcements.Len(); n != 0 {
+		t.Fatalf("%d subscribers left after the validator was disconnected", n)
+	}
+}
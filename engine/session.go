@@ -0,0 +1,237 @@
+package engine
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// session is one validator connection speaking either the legacy text
+// prompts or the JSON-lines protocol.
+type session interface {
+	// register reads the validator's registration.
+	register() (registration, error)
+	registered(address string, balance, round, reserve int, seed []byte, nonce uint64, resumed bool)
+	// registrationFailed tells the validator why it was turned away.
+	registrationFailed(reason string)
+	// nextBid blocks until the validator submits its next bid. The
+	// request's Address is filled in by the server.
+	nextBid() (BidRequest, error)
+	// bidAccepted confirms a bid and the round it counts toward.
+	bidAccepted(bpm, bid, round int)
+	bidRejected(reason string)
+	roundOpened(info RoundInfo)
+	roundClosed(info RoundInfo)
+	roundResult(result RoundResult)
+	balance(balance int)
+	// staked confirms a bond, unbond, delegation or commission request.
+	staked(balance, bonded, unbonding, delegated int)
+	chain(blocks []Block)
+}
+
+// registration is what a validator declares when it connects. PublicKey is
+// nil for legacy validators. A resuming validator instead proves it holds the
+// key of an existing account by signing the connection's Challenge. Any
+// balance the validator claims is ignored; the server's StakePolicy decides.
+// A Delegator opens a delegator account rather than a validator.
+type registration struct {
+	Delegator bool
+	PublicKey ed25519.PublicKey
+	Resume    bool
+	Challenge string
+	Signature []byte
+}
+
+// rejectReason maps a registration or SubmitBid error to the reason code
+// sent to clients.
+func rejectReason(err error) string {
+	switch {
+	case errors.Is(err, ErrInsufficientBalance):
+		return "insufficient_balance"
+	case errors.Is(err, ErrBadSignature), errors.Is(err, ErrBadResume):
+		return "bad_signature"
+	case errors.Is(err, ErrStaleNonce):
+		return "stale_nonce"
+	case errors.Is(err, ErrWrongRound):
+		return "wrong_round"
+	case errors.Is(err, ErrUnknownValidator):
+		return "unknown_validator"
+	case errors.Is(err, ErrAlreadyRegistered):
+		return "already_registered"
+	case errors.Is(err, ErrEvicted):
+		return "evicted"
+	case errors.Is(err, ErrSignatureRequired):
+		return "signature_required"
+	case errors.Is(err, ErrNotAllocated):
+		return "not_allocated"
+	case errors.Is(err, ErrMalformedBid):
+		return "malformed_bid"
+	case errors.Is(err, ErrNonPositiveBid):
+		return "non_positive_bid"
+	case errors.Is(err, ErrBidAboveCap):
+		return "above_cap"
+	case errors.Is(err, ErrBidGranularity):
+		return "bad_granularity"
+	case errors.Is(err, ErrBidIncrement):
+		return "below_increment"
+	case errors.Is(err, ErrBelowReserve):
+		return "below_reserve"
+	case errors.Is(err, ErrBondingDisabled):
+		return "bonding_disabled"
+	case errors.Is(err, ErrInsufficientStake):
+		return "insufficient_stake"
+	case errors.Is(err, ErrProofRequired):
+		return "proof_required"
+	case errors.Is(err, ErrBadProof):
+		return "bad_proof"
+	case errors.Is(err, ErrDelegator):
+		return "delegator"
+	case errors.Is(err, ErrNotValidator):
+		return "not_validator"
+	case errors.Is(err, ErrSelfDelegation):
+		return "self_delegation"
+	case errors.Is(err, ErrInsufficientDelegation):
+		return "insufficient_delegation"
+	case errors.Is(err, ErrBadCommission):
+		return "bad_commission"
+	case errors.Is(err, ErrInvalidBlock):
+		return "invalid_block"
+	case errors.Is(err, ErrEquivocation):
+		return "equivocation"
+	case errors.Is(err, ErrJailed):
+		return "jailed"
+	case errors.Is(err, ErrEjected):
+		return "ejected"
+	case errors.Is(err, ErrDuplicateBid):
+		return "duplicate_bid"
+	case errors.Is(err, ErrRoundClosed):
+		return "round_closed"
+	case errors.Is(err, ErrLateBidWaiting):
+		return "late_bid_waiting"
+	case errors.Is(err, ErrCommitRequired):
+		return "commit_required"
+	case errors.Is(err, ErrNotSealed):
+		return "not_sealed"
+	case errors.Is(err, ErrRevealClosed):
+		return "reveal_closed"
+	case errors.Is(err, ErrNoCommitment):
+		return "no_commitment"
+	case errors.Is(err, ErrCommitmentMismatch):
+		return "commitment_mismatch"
+	default:
+		return "invalid"
+	}
+}
+
+// textSession implements the original prompt-driven protocol so that nc and
+// telnet users keep working.
+type textSession struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	scanner *bufio.Scanner
+	// first is the line read while negotiating the protocol; in text mode it
+	// is the declared balance.
+	first string
+}
+
+func newTextSession(conn net.Conn, scanner *bufio.Scanner, first string) *textSession {
+	return &textSession{conn: conn, scanner: scanner, first: first}
+}
+
+func (t *textSession) write(s string) {
+	t.mu.Lock()
+	io.WriteString(t.conn, s)
+	t.mu.Unlock()
+}
+
+func (t *textSession) register() (registration, error) {
+	// The prompt still asks for a balance so existing scripts keep working,
+	// but the answer is only checked for being a number.
+	if _, err := strconv.Atoi(t.first); err != nil {
+		return registration{}, fmt.Errorf("%v not a number: %w", t.first, err)
+	}
+	return registration{}, nil
+}
+
+func (t *textSession) registered(address string, balance, round, reserve int, seed []byte, nonce uint64, resumed bool) {
+	t.write("\nEnter a new BPM:")
+}
+
+func (t *textSession) registrationFailed(reason string) {
+	t.write("\nRegistration rejected: " + reason + "\n")
+}
+
+func (t *textSession) nextBid() (BidRequest, error) {
+	if !t.scanner.Scan() {
+		return BidRequest{}, io.EOF
+	}
+	bpm, err := strconv.Atoi(t.scanner.Text())
+	if err != nil {
+		return BidRequest{}, fmt.Errorf("%w: BPM %q is not a number", ErrMalformedBid, t.scanner.Text())
+	}
+
+	t.write("\nSubmit your bid:")
+	if !t.scanner.Scan() {
+		return BidRequest{}, io.EOF
+	}
+	bid, err := strconv.Atoi(t.scanner.Text())
+	if err != nil {
+		return BidRequest{}, fmt.Errorf("%w: bid %q is not a number", ErrMalformedBid, t.scanner.Text())
+	}
+	return BidRequest{BPM: bpm, Bid: bid}, nil
+}
+
+func (t *textSession) bidAccepted(bpm, bid, round int) {
+	t.write("\nBid submitted, waiting for auction result.\nEnter a new BPM:")
+}
+
+func (t *textSession) bidRejected(reason string) {
+	t.write("\nBid rejected: " + reason + "\nEnter a new BPM:")
+}
+
+func (t *textSession) roundOpened(info RoundInfo) {
+	if info.Reserve > 0 {
+		t.write("\nRound " + strconv.Itoa(info.ID) + " is open for bids (reserve " + strconv.Itoa(info.Reserve) + ")\n")
+		return
+	}
+	t.write("\nRound " + strconv.Itoa(info.ID) + " is open for bids\n")
+}
+
+func (t *textSession) roundClosed(info RoundInfo) {
+	t.write("\nRound " + strconv.Itoa(info.ID) + " is closed for bids\n")
+}
+
+func (t *textSession) roundResult(result RoundResult) {
+	if result.Winner == "" {
+		t.write("\nRound " + strconv.Itoa(result.Round) + " produced no block\n")
+		return
+	}
+	t.write("\nwinning validator: " + result.Winner + "\nclearing price: " + strconv.Itoa(result.Price) + "\n")
+	if len(result.Block.Committee) > 1 {
+		t.write("committee: " + strings.Join(result.Block.Committee, ", ") + "\n")
+	}
+}
+
+func (t *textSession) balance(balance int) {
+	t.write("Your current balance: " + strconv.Itoa(balance) + "\n")
+}
+
+// staked is never called for text clients, which have no way to bond.
+func (t *textSession) staked(balance, bonded, unbonding, delegated int) {
+	t.write("Your current balance: " + strconv.Itoa(balance) + ", bonded " + strconv.Itoa(bonded) + "\n")
+}
+
+func (t *textSession) chain(blocks []Block) {
+	output, err := json.Marshal(blocks)
+	if err != nil {
+		return
+	}
+	t.write(string(output) + "\n")
+}
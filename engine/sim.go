@@ -0,0 +1,438 @@
+package engine
+
+import (
+	"container/heap"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// simEpoch is where every simulation's virtual clock starts so that block
+// timestamps do not depend on when the run happened.
+var simEpoch = time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// SimConfig describes the validator population of an in-process simulation.
+// The bidding behaviour mirrors the default tools/client validator.
+type SimConfig struct {
+	// Rounds is the number of rounds to settle before stopping.
+	Rounds     int
+	Validators int
+	Balance    int
+	BaseCost   int
+	MinBPM     int
+	MaxBPM     int
+	// OverbidLimit is the upper bound for each agent's overbid percentage.
+	OverbidLimit int
+	// BidInterval is the virtual time between bids from the same agent.
+	// Zero means one bid per round: the round interval plus the settlement
+	// delay. A bid that falls while no round is open waits for the next one.
+	BidInterval time.Duration
+	// RevealRate is the probability that an agent reveals its sealed bid
+	// under a SealedMechanism.
+	RevealRate float64
+	// BondFraction is the share of its balance each agent bonds at the start
+	// when the run selects by bonded stake.
+	BondFraction float64
+	// Delegators is the number of token holders that delegate BondFraction
+	// of their balance to a random validator instead of bidding. Each round
+	// they move it to another validator with probability Redelegate.
+	// Validators then charge a random commission of up to Commission.
+	// Delegators need at least one validator; the CLI rejects runs without.
+	Delegators int
+	Redelegate float64
+	Commission float64
+	// Equivocate is the probability that an agent follows a bid with a
+	// second, conflicting one in the same round.
+	Equivocate float64
+	// Quiet suppresses the per-round Gini line.
+	Quiet bool
+}
+
+// SimReport summarises a finished simulation.
+type SimReport struct {
+	Rounds int
+	Blocks int
+	// Gini is over the validators' holdings and HolderGini over every
+	// account's, delegators included.
+	Gini       float64
+	HolderGini float64
+	// Elapsed is the virtual time covered by the run.
+	Elapsed time.Duration
+	// Bids totals the per-round bid statistics.
+	Bids RoundStats
+	// Supply and Treasury are the token supply and treasury balance at the
+	// end of the run.
+	Supply   int
+	Treasury int
+	// Utility summarises the validators' net utility and Wins how the
+	// blocks were shared between them.
+	Utility UtilitySummary
+	Wins    WinSummary
+}
+
+// Simulation runs validator agents and the round scheduler as events on a
+// virtual clock. Bids and settlement go through the same RoundManager the TCP
+// server uses, so only the transport and the passage of time differ.
+type Simulation struct {
+	cfg      Config
+	sim      SimConfig
+	clock    *VirtualClock
+	chain    *Chain
+	registry *Registry
+	rounds   *RoundManager
+	queue    eventQueue
+	seq      int
+	settled  int
+	bids     RoundStats
+	agents   []*simAgent
+	// open is whether a round is collecting bids, and waiting the agents
+	// whose bid came due while none was.
+	open    bool
+	waiting []*simAgent
+}
+
+// NewSimulation creates a simulation of sim.Validators agents bidding under
+// cfg's mechanism and round interval.
+func NewSimulation(cfg Config, sim SimConfig) *Simulation {
+	if cfg.Gini == nil {
+		cfg.Gini = SortedGini
+	}
+	if cfg.RoundInterval <= 0 {
+		cfg.RoundInterval = 60 * time.Second
+	}
+	if sim.BaseCost <= 0 {
+		sim.BaseCost = 1
+	}
+	if sim.MinBPM >= sim.MaxBPM {
+		sim.MaxBPM = sim.MinBPM + 1
+	}
+	if sim.OverbidLimit <= 0 {
+		sim.OverbidLimit = 100
+	}
+	if _, sealed := cfg.Mechanism.(SealedMechanism); sealed && cfg.SettlementDelay <= 0 {
+		cfg.SettlementDelay = DefaultRevealWindow
+	}
+	if sim.BidInterval <= 0 {
+		sim.BidInterval = cfg.RoundInterval + cfg.SettlementDelay
+	}
+	if cfg.Economics.Split == (PriceSplit{}) {
+		cfg.Economics.Split = BurnAll
+	}
+
+	clock := NewVirtualClock(simEpoch)
+	chain := NewChain(clock.Now())
+	registry := NewRegistry()
+	return &Simulation{
+		cfg:      cfg,
+		sim:      sim,
+		clock:    clock,
+		chain:    chain,
+		registry: registry,
+		rounds:   NewRoundManager(chain, registry, cfg.Mechanism, clock, cfg.Bids, cfg.Economics, cfg.Seed),
+	}
+}
+
+// Chain returns the simulated chain.
+func (s *Simulation) Chain() *Chain {
+	return s.chain
+}
+
+// Ledgers returns every simulated validator's ledger.
+func (s *Simulation) Ledgers() map[string]Ledger {
+	return s.registry.Ledgers()
+}
+
+// RunInfo describes the simulation for the export.
+func (s *Simulation) RunInfo() RunInfo {
+	return RunInfo{Mechanism: s.cfg.Mechanism.Name(), Seed: s.cfg.Seed, Committee: s.cfg.Committee.String(), Weighting: s.cfg.Weighting.String(), Reserve: s.cfg.Bids.Reserve.String(), Economics: s.cfg.Economics}
+}
+
+// Run executes events until the configured number of rounds has settled.
+func (s *Simulation) Run() SimReport {
+	for i := 0; i < s.sim.Validators; i++ {
+		agent := s.newAgent(i)
+		s.agents = append(s.agents, agent)
+		s.schedule(s.clock.Now().Add(agent.stagger), agent.bid)
+	}
+	for i := 0; i < s.sim.Delegators; i++ {
+		delegator := s.newDelegator(i)
+		if s.sim.Redelegate > 0 {
+			s.schedule(s.clock.Now().Add(s.cfg.RoundInterval), delegator.redelegate)
+		}
+	}
+	s.openRound()
+
+	for s.settled < s.sim.Rounds && s.queue.Len() > 0 {
+		ev := heap.Pop(&s.queue).(*event)
+		s.clock.Set(ev.at)
+		ev.fire()
+	}
+
+	ledgers := s.registry.Ledgers()
+	wins := make([]int, len(s.agents))
+	for i, agent := range s.agents {
+		wins[i] = ledgers[agent.address].Wins
+	}
+	return SimReport{
+		Rounds:     s.settled,
+		Blocks:     s.chain.Len() - 1,
+		Gini:       s.cfg.Gini(s.registry.ValidatorBalances()),
+		HolderGini: s.cfg.Gini(s.registry.Balances()),
+		Elapsed:    s.clock.Now().Sub(simEpoch),
+		Bids:       s.bids,
+		Supply:     s.registry.Supply(),
+		Treasury:   s.registry.Treasury(),
+		Utility:    SummarizeUtility(s.registry.ValidatorLedgers()),
+		Wins:       SummarizeWins(wins, s.cfg.Gini),
+	}
+}
+
+// openRound opens the next round and lets the agents that were waiting for it
+// bid, each after its usual stagger.
+func (s *Simulation) openRound() {
+	s.rounds.Open()
+	s.open = true
+	for _, agent := range s.waiting {
+		s.schedule(s.clock.Now().Add(agent.stagger), agent.bid)
+	}
+	s.waiting = nil
+	s.schedule(s.clock.Now().Add(s.cfg.RoundInterval), s.closeRound)
+}
+
+func (s *Simulation) closeRound() {
+	s.open = false
+	info := s.rounds.Close()
+	if _, sealed := s.cfg.Mechanism.(SealedMechanism); sealed {
+		for _, agent := range s.agents {
+			agent.reveal(info.ID)
+		}
+	}
+	if s.cfg.SettlementDelay > 0 {
+		s.schedule(s.clock.Now().Add(s.cfg.SettlementDelay), s.settleRound)
+		return
+	}
+	s.settleRound()
+}
+
+func (s *Simulation) settleRound() {
+	result, _ := s.rounds.Settle()
+	s.bids.add(result.Stats)
+	s.settled++
+	if !s.sim.Quiet {
+		fmt.Println("Gini Coefficient: ", s.cfg.Gini(s.registry.ValidatorBalances()))
+	}
+	s.openRound()
+}
+
+func (s *Simulation) schedule(at time.Time, fire func()) {
+	s.seq++
+	heap.Push(&s.queue, &event{at: at, seq: s.seq, fire: fire})
+}
+
+// simAgent is a validator that bids like the default client simulator:
+// uniformly in [1, BaseCost], with a per-agent tendency to overbid into
+// [BaseCost+1, 2*BaseCost].
+type simAgent struct {
+	s              *Simulation
+	address        string
+	rng            *rand.Rand
+	stagger        time.Duration
+	overbidPercent int
+	overbidActive  bool
+	// sealed is the agent's last commitment under a SealedMechanism.
+	sealed *BidRequest
+	// key signs the agent's requests and proves its sortition tickets. Only
+	// sortition runs give agents keys; the others keep them anonymous.
+	key   ed25519.PrivateKey
+	nonce uint64
+}
+
+func (s *Simulation) newAgent(id int) *simAgent {
+	name := fmt.Sprintf("sim-validator-%d", id)
+	agent := &simAgent{s: s, address: CalculateHash(name)}
+	if usesSortition(s.cfg.Mechanism) {
+		// Keys are derived from the agent's name so that runs stay
+		// reproducible.
+		seed := sha256.Sum256([]byte(name))
+		agent.key = ed25519.NewKeyFromSeed(seed[:])
+		agent.address, _ = s.registry.RegisterKey(agent.key.Public().(ed25519.PublicKey), s.sim.Balance)
+	} else {
+		s.registry.Register(agent.address, s.sim.Balance)
+	}
+	if s.cfg.Economics.Bonding.Enabled {
+		bond := BidRequest{Kind: BondStake, Bid: int(float64(s.sim.Balance) * s.sim.BondFraction)}
+		if _, err := agent.submit(bond); err != nil {
+			log.Printf("[sim] %s: bond: %v", agent.address[:8], err)
+		}
+	}
+
+	// Stream 0 of the seed belongs to the round manager, agents start at 1.
+	agent.rng = rand.New(rand.NewSource(DeriveSeed(s.cfg.Seed, int64(id)+1)))
+	agent.stagger = time.Duration(agent.rng.Intn(500)) * time.Millisecond
+	agent.overbidPercent = agent.rng.Intn(s.sim.OverbidLimit) + 1
+	agent.overbidActive = agent.rng.Intn(s.sim.OverbidLimit) < agent.overbidPercent
+	if s.cfg.Economics.Bonding.Enabled && s.sim.Delegators > 0 {
+		commission := BidRequest{Kind: SetCommission, Bid: agent.rng.Intn(int(s.sim.Commission*MaxCommission) + 1)}
+		if _, err := agent.submit(commission); err != nil {
+			log.Printf("[sim] %s: commission: %v", agent.address[:8], err)
+		}
+	}
+	return agent
+}
+
+// submit sends req on the agent's behalf. An agent with a key signs it with
+// its next nonce, and proves its sortition ticket for bids and commitments.
+func (a *simAgent) submit(req BidRequest) (int, error) {
+	req.Address = a.address
+	if a.key != nil {
+		if req.Kind == PlainBid || req.Kind == CommitBid {
+			req.Round = a.s.rounds.Round()
+			req.Proof, _ = ProveSortition(a.key, a.s.rounds.Seed())
+		}
+		a.nonce++
+		req.Nonce = a.nonce
+		SignBid(a.key, &req)
+	}
+	return a.s.rounds.SubmitBid(req)
+}
+
+func (a *simAgent) bid() {
+	if !a.s.open {
+		// Wait for the next round rather than bid into the settlement gap.
+		a.s.waiting = append(a.s.waiting, a)
+		return
+	}
+	cfg := a.s.sim
+	bpm := cfg.MinBPM + a.rng.Intn(cfg.MaxBPM-cfg.MinBPM+1)
+
+	bid := a.rng.Intn(cfg.BaseCost) + 1
+	if a.overbidActive {
+		overbidCheck := a.rng.Intn(cfg.OverbidLimit) + 1
+		if overbidCheck <= a.overbidPercent {
+			bid = cfg.BaseCost + a.rng.Intn(cfg.BaseCost) + 1
+		}
+	}
+
+	req := BidRequest{BPM: bpm, Bid: bid}
+	if _, sealed := a.s.cfg.Mechanism.(SealedMechanism); sealed {
+		req.Kind = CommitBid
+		req.Round = a.s.rounds.Round()
+		req.Salt = make([]byte, 16)
+		a.rng.Read(req.Salt)
+		req.Commitment = Commitment(a.address, req.Round, bid, req.Salt)
+	}
+	round, err := a.submit(req)
+	if err != nil && !a.s.sim.Quiet {
+		log.Printf("[sim] %s: %v", a.address[:8], err)
+	}
+	if err == nil && req.Kind == CommitBid {
+		req.Round = round
+		a.sealed = &req
+	}
+	if err == nil && cfg.Equivocate > 0 && a.rng.Float64() < cfg.Equivocate {
+		a.equivocate(req)
+	}
+	a.s.schedule(a.s.clock.Now().Add(cfg.BidInterval), a.bid)
+}
+
+// equivocate submits a second bid that conflicts with req.
+func (a *simAgent) equivocate(req BidRequest) {
+	req.Bid++
+	if req.Kind == CommitBid {
+		req.Commitment = Commitment(a.address, req.Round, req.Bid, req.Salt)
+	}
+	if _, err := a.submit(req); err != nil && !a.s.sim.Quiet {
+		log.Printf("[sim] %s: %v", a.address[:8], err)
+	}
+}
+
+// reveal opens the agent's commitment for round, unless the agent is one of
+// the RevealRate share that walks away.
+func (a *simAgent) reveal(round int) {
+	if a.sealed == nil || a.sealed.Round != round {
+		return
+	}
+	req := *a.sealed
+	a.sealed = nil
+	if a.rng.Float64() >= a.s.sim.RevealRate {
+		return
+	}
+	req.Kind = RevealBid
+	if _, err := a.submit(req); err != nil && !a.s.sim.Quiet {
+		log.Printf("[sim] %s: reveal: %v", a.address[:8], err)
+	}
+}
+
+// simDelegator is a token holder that stakes through a validator.
+type simDelegator struct {
+	s         *Simulation
+	address   string
+	rng       *rand.Rand
+	validator string
+}
+
+func (s *Simulation) newDelegator(id int) *simDelegator {
+	address := CalculateHash(fmt.Sprintf("sim-delegator-%d", id))
+	s.registry.Register(address, s.sim.Balance)
+	s.registry.SetDelegator(address)
+
+	// Delegators take the streams after the validators'.
+	rng := rand.New(rand.NewSource(DeriveSeed(s.cfg.Seed, int64(s.sim.Validators+id)+1)))
+	d := &simDelegator{s: s, address: address, rng: rng, validator: s.agents[rng.Intn(len(s.agents))].address}
+	req := BidRequest{Address: address, Kind: Delegate, Validator: d.validator, Bid: int(float64(s.sim.Balance) * s.sim.BondFraction)}
+	if _, err := s.rounds.SubmitBid(req); err != nil {
+		log.Printf("[sim] %s: delegate: %v", address[:8], err)
+	}
+	return d
+}
+
+// redelegate moves the delegator's whole stake to another random validator,
+// with probability Redelegate, once a round.
+func (d *simDelegator) redelegate() {
+	if d.rng.Float64() < d.s.sim.Redelegate {
+		to := d.s.agents[d.rng.Intn(len(d.s.agents))].address
+		amount := d.s.registry.Delegations(d.address)
+		if to != d.validator && amount > 0 {
+			req := BidRequest{Address: d.address, Kind: Redelegate, From: d.validator, Validator: to, Bid: amount}
+			if _, err := d.s.rounds.SubmitBid(req); err != nil && !d.s.sim.Quiet {
+				log.Printf("[sim] %s: redelegate: %v", d.address[:8], err)
+			} else if err == nil {
+				d.validator = to
+			}
+		}
+	}
+	d.s.schedule(d.s.clock.Now().Add(d.s.cfg.RoundInterval), d.redelegate)
+}
+
+type event struct {
+	at   time.Time
+	seq  int
+	fire func()
+}
+
+// eventQueue orders events by time, breaking ties by scheduling order so that
+// runs are deterministic.
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].at.Before(q[j].at)
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(*event)) }
+
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	ev := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return ev
+}
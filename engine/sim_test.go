@@ -0,0 +1,37 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestSimulationNonRevealDeterministic checks that two runs with the same
+// seed produce the same chain when several validators fail to reveal in a
+// round and are slashed for it.
+func TestSimulationNonRevealDeterministic(t *testing.T) {
+	run := func() []Block {
+		policy, err := ParseSlashingPolicy("non-reveal=5%")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := Config{Mechanism: NewCommitReveal(NewVickreyLottery(), 10), Seed: 7}
+		cfg.Economics.Slashing = policy
+		sim := NewSimulation(cfg, SimConfig{Rounds: 20, Validators: 12, Balance: 1000, BaseCost: 15, MinBPM: 60, MaxBPM: 80, RevealRate: 0.5, Quiet: true})
+		sim.Run()
+		return sim.Chain().Blocks()
+	}
+	first, second := run(), run()
+
+	slashed := 0
+	for _, block := range first {
+		if len(block.Evidence) > 1 {
+			slashed++
+		}
+	}
+	if slashed == 0 {
+		t.Fatal("no block records more than one non-reveal")
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Fatal("two runs with the same seed produced different chains")
+	}
+}
@@ -0,0 +1,253 @@
+package engine
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidBlock is returned for a bid whose candidate block carries a
+	// BPM no reading can have.
+	ErrInvalidBlock = errors.New("proposed block is invalid")
+	// ErrEquivocation is returned for a second, different bid or commitment
+	// from the same validator in one round.
+	ErrEquivocation = errors.New("conflicting bid in the same round")
+	// ErrJailed is returned for bids from a jailed validator.
+	ErrJailed = errors.New("validator is jailed")
+	// ErrEjected is returned for bids from an ejected validator.
+	ErrEjected = errors.New("validator has been ejected")
+)
+
+// Offence is misbehaviour that can be slashed.
+type Offence int
+
+const (
+	// InvalidBlock is proposing a block with a BPM of zero or less.
+	InvalidBlock Offence = iota
+	// Equivocation is submitting two different bids or commitments in one
+	// round.
+	Equivocation
+	// NonReveal is committing to a sealed bid and never revealing it.
+	NonReveal
+)
+
+var offenceNames = map[Offence]string{
+	InvalidBlock: "invalid-block",
+	Equivocation: "equivocation",
+	NonReveal:    "non-reveal",
+}
+
+func (o Offence) String() string {
+	return offenceNames[o]
+}
+
+// Penalty is what an offence costs: Fraction of the validator's bonded and
+// unbonding stake (of its balance when the run does not bond stake), Jail
+// rounds without bidding, or ejection for good.
+type Penalty struct {
+	Fraction float64
+	Jail     int
+	Eject    bool
+}
+
+func (p Penalty) String() string {
+	var parts []string
+	if p.Fraction > 0 {
+		parts = append(parts, strconv.FormatFloat(p.Fraction*100, 'f', -1, 64)+"%")
+	}
+	if p.Jail > 0 {
+		parts = append(parts, "jail:"+strconv.Itoa(p.Jail))
+	}
+	if p.Eject {
+		parts = append(parts, "eject")
+	}
+	return strings.Join(parts, "+")
+}
+
+// SlashingPolicy is the penalty of every offence that is slashed. Offences
+// it does not list are still rejected but go unpunished.
+type SlashingPolicy map[Offence]Penalty
+
+// ParseSlashingPolicy parses "none" or a comma separated list of
+// OFFENCE=PENALTY, where PENALTY joins any of "N%", "jail:N" and "eject"
+// with "+", e.g. "equivocation=50%+jail:10,non-reveal=5%".
+func ParseSlashingPolicy(spec string) (SlashingPolicy, error) {
+	policy := SlashingPolicy{}
+	if spec == "none" || spec == "" {
+		return policy, nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		name, penaltySpec, ok := strings.Cut(entry, "=")
+		offence, known := Offence(-1), false
+		for o, n := range offenceNames {
+			if n == name {
+				offence, known = o, true
+			}
+		}
+		if !ok || !known {
+			return nil, fmt.Errorf("slashing %q: want OFFENCE=PENALTY with OFFENCE one of invalid-block, equivocation, non-reveal", entry)
+		}
+		penalty := Penalty{}
+		for _, part := range strings.Split(penaltySpec, "+") {
+			switch {
+			case part == "eject":
+				penalty.Eject = true
+			case strings.HasPrefix(part, "jail:"):
+				n, err := strconv.Atoi(strings.TrimPrefix(part, "jail:"))
+				if err != nil || n < 1 {
+					return nil, fmt.Errorf("slashing %q: invalid jail term %q", entry, part)
+				}
+				penalty.Jail = n
+			case strings.HasSuffix(part, "%"):
+				pct, err := strconv.ParseFloat(strings.TrimSuffix(part, "%"), 64)
+				if err != nil || pct <= 0 || pct > 100 {
+					return nil, fmt.Errorf("slashing %q: invalid percentage %q", entry, part)
+				}
+				penalty.Fraction = pct / 100
+			default:
+				return nil, fmt.Errorf("slashing %q: unknown penalty %q (want N%%, jail:N or eject)", entry, part)
+			}
+		}
+		policy[offence] = penalty
+	}
+	return policy, nil
+}
+
+func (p SlashingPolicy) String() string {
+	if len(p) == 0 {
+		return "none"
+	}
+	entries := make([]string, 0, len(p))
+	for offence, penalty := range p {
+		entries = append(entries, offence.String()+"="+penalty.String())
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// Evidence records a slashed offence in the chain. Proof holds what the
+// offender submitted: the signed payloads and signatures of conflicting bids,
+// the unopened commitment or the rejected block, so that anyone can check it.
+type Evidence struct {
+	Round     int
+	Validator string
+	Offence   string
+	Penalty   string
+	Slashed   int
+	Proof     []string
+}
+
+// proof renders a submitted request as evidence: its signed payload and, for
+// validators with a key, its signature.
+func proof(req BidRequest) string {
+	if req.Signature == nil {
+		return string(req.payload())
+	}
+	return string(req.payload()) + "#" + hex.EncodeToString(req.Signature)
+}
+
+// Slash takes fraction of a validator's bonded and unbonding stake and of the
+// stake delegated to it, or of its balance when bonded is false, and returns
+// the amount destroyed.
+func (r *Registry) Slash(address string, fraction float64, bonded bool) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, ok := r.nodes[address]
+	if !ok || fraction <= 0 {
+		return 0
+	}
+	cut := func(amount *int) int {
+		n := int(float64(*amount) * fraction)
+		*amount -= n
+		return n
+	}
+	if !bonded {
+		slashed := cut(&node.Balance)
+		node.Ledger.Slashed += slashed
+		return slashed
+	}
+	slashed := cut(&node.Bonded)
+	for i := range node.Unbonding {
+		slashed += cut(&node.Unbonding[i].Amount)
+	}
+	node.Ledger.Slashed += slashed
+	// Stake delegated to the validator shares its fate.
+	for _, delegator := range r.nodes {
+		stake, ok := delegator.Delegations[address]
+		if !ok {
+			continue
+		}
+		n := cut(&stake)
+		delegator.undelegate(address, n)
+		delegator.Ledger.Slashed += n
+		slashed += n
+	}
+	return slashed
+}
+
+// Jail stops a validator from bidding until round until has passed.
+func (r *Registry) Jail(address string, until int) {
+	r.mu.Lock()
+	if node, ok := r.nodes[address]; ok && until > node.JailedUntil {
+		node.JailedUntil = until
+	}
+	r.mu.Unlock()
+}
+
+// Eject stops a validator from ever bidding again. Its tokens stay its own.
+func (r *Registry) Eject(address string) {
+	r.mu.Lock()
+	if node, ok := r.nodes[address]; ok {
+		node.Ejected = true
+	}
+	r.mu.Unlock()
+}
+
+// Eligible reports whether a validator may bid in round.
+func (r *Registry) Eligible(address string, round int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, ok := r.nodes[address]
+	switch {
+	case !ok:
+		return ErrUnknownValidator
+	case node.Delegator:
+		return ErrDelegator
+	case node.Ejected:
+		return ErrEjected
+	case round <= node.JailedUntil:
+		return ErrJailed
+	}
+	return nil
+}
+
+// slash punishes offence by address according to the slashing policy and
+// queues the evidence for the next block. It reports whether the offence is
+// slashed at all.
+func (m *RoundManager) slash(address string, offence Offence, proofs ...string) bool {
+	penalty, ok := m.economics.Slashing[offence]
+	if !ok {
+		return false
+	}
+	slashed := m.registry.Slash(address, penalty.Fraction, m.economics.Bonding.Enabled)
+	if penalty.Jail > 0 {
+		m.registry.Jail(address, m.round+penalty.Jail)
+	}
+	if penalty.Eject {
+		m.registry.Eject(address)
+	}
+	m.stats.Slashed += slashed
+	m.evidence = append(m.evidence, Evidence{
+		Round:     m.round,
+		Validator: address,
+		Offence:   offence.String(),
+		Penalty:   penalty.String(),
+		Slashed:   slashed,
+		Proof:     proofs,
+	})
+	return true
+}
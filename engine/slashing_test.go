@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSlashing(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		bonded bool
+		// bids are submitted in turn; want is the error for the last.
+		bids []BidRequest
+		want error
+		// offence is the evidence recorded, "" for none.
+		offence string
+		proofs  int
+		slashed int
+		stake   [2]int
+		jailed  bool
+	}{
+		{"equivocation", "equivocation=50%+jail:2", true, []BidRequest{{BPM: 70, Bid: 10}, {BPM: 70, Bid: 20}}, ErrEquivocation, "equivocation", 2, 200, [2]int{150, 50}, true},
+		{"conflicting block", "equivocation=50%", true, []BidRequest{{BPM: 70, Bid: 10}, {BPM: 71, Bid: 10}}, ErrEquivocation, "equivocation", 2, 200, [2]int{150, 50}, false},
+		{"retransmission", "equivocation=50%", true, []BidRequest{{BPM: 70, Bid: 10}, {BPM: 70, Bid: 10}}, ErrDuplicateBid, "", 0, 0, [2]int{300, 100}, false},
+		{"equivocation not slashed", "invalid-block=10%", true, []BidRequest{{BPM: 70, Bid: 10}, {BPM: 70, Bid: 20}}, ErrDuplicateBid, "", 0, 0, [2]int{300, 100}, false},
+		{"invalid block", "invalid-block=10%", true, []BidRequest{{BPM: 0, Bid: 20}}, ErrInvalidBlock, "invalid-block", 1, 40, [2]int{270, 90}, false},
+		{"invalid block not slashed", "none", true, []BidRequest{{BPM: -1, Bid: 20}}, ErrInvalidBlock, "", 0, 0, [2]int{300, 100}, false},
+		{"balance without bonding", "equivocation=50%", false, []BidRequest{{BPM: 70, Bid: 10}, {BPM: 70, Bid: 20}}, ErrEquivocation, "equivocation", 2, 295, [2]int{300, 100}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParseSlashingPolicy(tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			economics := Economics{Slashing: policy, Bonding: Bonding{Enabled: tt.bonded, UnbondingRounds: 10}}
+			rounds, registry, key, address := testRounds(t, NewVickreyLottery(), BidRules{}, economics)
+			// 400 bonded, of which 100 unbonding until round 3.
+			if err := registry.Bond(address, 400); err != nil {
+				t.Fatal(err)
+			}
+			if err := registry.Unbond(address, 100, 3); err != nil {
+				t.Fatal(err)
+			}
+			registry.Register("honest", 1000)
+			if err := registry.Bond("honest", 100); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := rounds.SubmitBid(BidRequest{Address: "honest", BPM: 70, Bid: 10}); err != nil {
+				t.Fatal(err)
+			}
+			for i, bid := range tt.bids {
+				_, err := rounds.SubmitBid(signed(key, address, 1, uint64(i)+1, bid))
+				if i < len(tt.bids)-1 && err != nil {
+					t.Fatal(err)
+				}
+				if i == len(tt.bids)-1 && !errors.Is(err, tt.want) {
+					t.Fatalf("error %v, want %v", err, tt.want)
+				}
+			}
+			if bonded, unbonding := registry.Stake(address); bonded != tt.stake[0] || unbonding != tt.stake[1] {
+				t.Fatalf("stake %d bonded and %d unbonding, want %d and %d", bonded, unbonding, tt.stake[0], tt.stake[1])
+			}
+			if slashed := registry.Ledgers()[address].Slashed; slashed != tt.slashed {
+				t.Fatalf("slashed %d, want %d", slashed, tt.slashed)
+			}
+			if jailed := errors.Is(registry.Eligible(address, 2), ErrJailed); jailed != tt.jailed {
+				t.Fatalf("jailed %v, want %v", jailed, tt.jailed)
+			}
+
+			result, ok := rounds.Settle()
+			if !ok {
+				t.Fatal("no block settled")
+			}
+			evidence := result.Block.Evidence
+			if tt.offence == "" {
+				if len(evidence) != 0 {
+					t.Fatalf("evidence %+v for an unslashed offence", evidence)
+				}
+				return
+			}
+			if len(evidence) != 1 {
+				t.Fatalf("evidence %+v, want one entry", evidence)
+			}
+			e := evidence[0]
+			if e.Round != 1 || e.Validator != address || e.Offence != tt.offence || e.Slashed != tt.slashed || len(e.Proof) != tt.proofs {
+				t.Fatalf("evidence %+v, want %s by %s slashing %d with %d proofs", e, tt.offence, address, tt.slashed, tt.proofs)
+			}
+
+			rounds.Open()
+			if result, _ := rounds.Settle(); len(result.Block.Evidence) != 0 {
+				t.Fatalf("evidence %+v recorded twice", result.Block.Evidence)
+			}
+		})
+	}
+}
+
+func TestSlashedUnbondingReleasesTheRest(t *testing.T) {
+	r := NewRegistry()
+	r.Register("v", 1000)
+	if err := r.Bond("v", 400); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Unbond("v", 100, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Unbond("v", 100, 5); err != nil {
+		t.Fatal(err)
+	}
+	supply := r.Supply()
+	if slashed := r.Slash("v", 0.25, true); slashed != 100 {
+		t.Fatalf("slashed %d of 400 at 25%%, want 100", slashed)
+	}
+	if got := supply - r.Supply(); got != 100 {
+		t.Fatalf("supply fell by %d, want the 100 slashed", got)
+	}
+
+	steps := []struct {
+		round              int
+		balance, unbonding int
+	}{
+		{1, 600, 150},
+		{2, 675, 75},
+		{5, 750, 0},
+	}
+	for _, step := range steps {
+		r.ReleaseUnbonded(step.round)
+		balance, _ := r.Balance("v")
+		bonded, unbonding := r.Stake("v")
+		if balance != step.balance || unbonding != step.unbonding || bonded != 150 {
+			t.Fatalf("round %d: balance %d, bonded %d, unbonding %d; want %d, 150, %d", step.round, balance, bonded, unbonding, step.balance, step.unbonding)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package engine
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+var (
+	// ErrProofRequired is returned for bids without a sortition proof in a
+	// sortition round, and for validators without a key to prove with.
+	ErrProofRequired = errors.New("sortition rounds need a VRF proof from a registered key")
+	// ErrBadProof is returned when a sortition proof does not verify against
+	// the validator's key and the round seed.
+	ErrBadProof = errors.New("VRF proof does not verify")
+)
+
+// DefaultSortitionSize is the expected number of validators sortition
+// selects per round when none is configured.
+const DefaultSortitionSize = 2
+
+// SortitionSeed is the public randomness of a round: the SHA-256 digest of
+// the hash of the block the round extends and the round number. Every
+// validator can compute it, and nobody can choose it without producing a
+// block.
+func SortitionSeed(prevHash string, round int) []byte {
+	sum := sha256.Sum256([]byte("seed|" + prevHash + "|" + strconv.Itoa(round)))
+	return sum[:]
+}
+
+// SortitionPayload is the VRF input of the sortition of the round with seed.
+func SortitionPayload(seed []byte) []byte {
+	return []byte("sortition|" + hex.EncodeToString(seed))
+}
+
+// ProveSortition evaluates the VRF of RFC 9381 (ECVRF-EDWARDS25519-SHA512-TAI)
+// on the round seed with the validator's Ed25519 key. The output is a hash of
+// a curve point only the key can compute and anyone can check against the
+// proof, and there is exactly one output per key and seed: unlike a
+// signature, a proof cannot be re-randomised into a better output.
+func ProveSortition(priv ed25519.PrivateKey, seed []byte) (proof, output []byte) {
+	return vrfProve(priv, SortitionPayload(seed))
+}
+
+// VerifySortition checks a proof against the validator's key and the round
+// seed and returns its output.
+func VerifySortition(pub ed25519.PublicKey, seed, proof []byte) ([]byte, bool) {
+	return vrfVerify(pub, SortitionPayload(seed), proof)
+}
+
+// Ticket is a validator's entry in a round's sortition: the key and proof
+// anyone can check, and the stake it was weighed by.
+type Ticket struct {
+	Validator string
+	PublicKey string
+	Proof     string
+	Stake     int
+	// output is the VRF output of a proof the server already verified.
+	output []byte
+}
+
+// SortitionRecord is everything needed to re-run a round's sortition. It is
+// stored in the block the round produced.
+type SortitionRecord struct {
+	Seed       string
+	Size       float64
+	TotalStake int
+	Tickets    []Ticket
+}
+
+// Draw is the result of checking one ticket.
+type Draw struct {
+	Ticket
+	// Value is the output as a fraction in [0, 1) and Threshold the value
+	// it had to stay below to be selected.
+	Value     float64
+	Threshold float64
+}
+
+// Selected reports whether the ticket passed its stake-weighted threshold.
+func (d Draw) Selected() bool {
+	return d.Value < d.Threshold
+}
+
+// Priority orders selected tickets: the lowest passes its threshold by the
+// widest margin and leads.
+func (d Draw) Priority() float64 {
+	return d.Value / d.Threshold
+}
+
+// Verify checks every ticket of the record and returns the selected ones,
+// leader first. A validator with stake s out of a total S is selected when
+// its output, read as a fraction, is below Size*s/S, so Size validators are
+// selected on average. Ties on priority are broken by address.
+func (r SortitionRecord) Verify() ([]Draw, error) {
+	seed, err := hex.DecodeString(r.Seed)
+	if err != nil {
+		return nil, fmt.Errorf("seed: %w", err)
+	}
+	return r.selected(func(ticket Ticket) ([]byte, error) {
+		pub, err := ParsePublicKey(ticket.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		if AddressFromKey(pub) != ticket.Validator {
+			return nil, errors.New("key belongs to another address")
+		}
+		proof, err := hex.DecodeString(ticket.Proof)
+		if err != nil {
+			return nil, fmt.Errorf("proof: %w", err)
+		}
+		output, ok := VerifySortition(pub, seed, proof)
+		if !ok {
+			return nil, ErrBadProof
+		}
+		return output, nil
+	})
+}
+
+// selected returns the selected tickets, leader first, given the VRF output
+// of every ticket.
+func (r SortitionRecord) selected(output func(Ticket) ([]byte, error)) ([]Draw, error) {
+	total := 0
+	for _, ticket := range r.Tickets {
+		total += ticket.Stake
+	}
+	if total != r.TotalStake {
+		return nil, fmt.Errorf("tickets add up to %d stake, not %d", total, r.TotalStake)
+	}
+	var selected []Draw
+	for _, ticket := range r.Tickets {
+		output, err := output(ticket)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ticket.Validator, err)
+		}
+		draw := Draw{Ticket: ticket, Value: fraction(output), Threshold: r.threshold(ticket.Stake)}
+		if draw.Selected() {
+			selected = append(selected, draw)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		if selected[i].Priority() == selected[j].Priority() {
+			return selected[i].Validator < selected[j].Validator
+		}
+		return selected[i].Priority() < selected[j].Priority()
+	})
+	return selected, nil
+}
+
+func (r SortitionRecord) threshold(stake int) float64 {
+	if r.TotalStake <= 0 || stake <= 0 {
+		return 0
+	}
+	threshold := r.Size * float64(stake) / float64(r.TotalStake)
+	if threshold > 1 {
+		return 1
+	}
+	return threshold
+}
+
+// fraction reads the first 8 bytes of a VRF output as a number in [0, 1).
+func fraction(output []byte) float64 {
+	return float64(binary.BigEndian.Uint64(output[:8])>>11) / (1 << 53)
+}
+
+// VerifyBlockSortition re-runs the sortition recorded in block, which
+// extends the block with hash prevHash, and checks that it selected the
+// block's validator and committee.
+func VerifyBlockSortition(block Block, prevHash string) error {
+	record := block.Sortition
+	if record == nil {
+		return errors.New("block has no sortition record")
+	}
+	if record.Seed != hex.EncodeToString(SortitionSeed(prevHash, block.Round)) {
+		return errors.New("seed does not match the previous block and round")
+	}
+	selected, err := record.Verify()
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 || selected[0].Validator != block.Validator {
+		return fmt.Errorf("validator %s was not the sortition leader", block.Validator)
+	}
+	for i, member := range block.Committee {
+		if i >= len(selected) || selected[i].Validator != member {
+			return fmt.Errorf("committee member %s was not selected in that place", member)
+		}
+	}
+	return nil
+}
+
+// Sortition selects proposers the way Algorand does, without trusting the
+// server's RNG. Every bid carries a VRF proof over the round seed. The
+// validators whose outputs pass their stake-weighted thresholds are selected
+// and the one with the best priority leads. Stake is the bidder's bonded
+// stake under Bonding and its balance otherwise. The leader pays the reserve
+// price and every other bid is refunded.
+type Sortition struct {
+	// Size is the expected number of validators selected per round.
+	Size float64
+}
+
+// NewSortition returns the sortition mechanism with size validators
+// selected per round on average.
+func NewSortition(size float64) *Sortition {
+	if size <= 0 {
+		size = DefaultSortitionSize
+	}
+	return &Sortition{Size: size}
+}
+
+// Name implements SelectionMechanism.
+func (s *Sortition) Name() string {
+	return "sortition"
+}
+
+func (s *Sortition) sortition() bool { return true }
+
+// Select implements SelectionMechanism. It never draws from rng.
+func (s *Sortition) Select(round Round, rng *rand.Rand) Outcome {
+	outcome := s.SelectCommittee(round, 1, rng)
+	outcome.Committee = nil
+	return outcome
+}
+
+// SelectCommittee picks the k selected validators with the best priority.
+func (s *Sortition) SelectCommittee(round Round, k int, rng *rand.Rand) Outcome {
+	record := &SortitionRecord{Seed: hex.EncodeToString(round.Seed), Size: s.Size}
+	for _, bidItem := range round.Bids {
+		ticket, ok := round.Tickets[bidItem.NodeAddress]
+		if !ok {
+			continue
+		}
+		ticket.Stake = round.Balances[bidItem.NodeAddress]
+		if round.Weights != nil {
+			ticket.Stake = round.Weights[bidItem.NodeAddress]
+		}
+		record.TotalStake += ticket.Stake
+		record.Tickets = append(record.Tickets, ticket)
+	}
+	// Proofs were checked when the bids were admitted.
+	selected, _ := record.selected(func(ticket Ticket) ([]byte, error) { return ticket.output, nil })
+	if len(selected) == 0 {
+		return Outcome{}
+	}
+	if len(selected) > k {
+		selected = selected[:k]
+	}
+	price := withReserve(0, round.Reserve)
+	outcome := Outcome{Winner: selected[0].Validator, Price: price, Payments: map[string]int{selected[0].Validator: price}, Sortition: record}
+	for _, draw := range selected {
+		outcome.Committee = append(outcome.Committee, draw.Validator)
+	}
+	return outcome
+}
+
+// usesSortition reports whether m, or the mechanism it wraps, is Sortition.
+func usesSortition(m SelectionMechanism) bool {
+	s, ok := m.(interface{ sortition() bool })
+	return ok && s.sortition()
+}
+
+func (c *Committee) sortition() bool    { return usesSortition(c.inner) }
+func (c *CommitReveal) sortition() bool { return usesSortition(c.inner) }
+
+// checkProof verifies the sortition proof of a bid against the round seed and
+// keeps it for settlement.
+func (m *RoundManager) checkProof(req BidRequest) error {
+	if !usesSortition(m.mechanism) {
+		return nil
+	}
+	pub, ok := m.registry.PublicKey(req.Address)
+	if !ok || len(req.Proof) == 0 {
+		return ErrProofRequired
+	}
+	output, ok := VerifySortition(pub, m.seed, req.Proof)
+	if !ok {
+		return ErrBadProof
+	}
+	m.tickets[req.Address] = Ticket{
+		Validator: req.Address,
+		PublicKey: hex.EncodeToString(pub),
+		Proof:     hex.EncodeToString(req.Proof),
+		output:    output,
+	}
+	return nil
+}
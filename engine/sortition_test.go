@@ -0,0 +1,312 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// sortitionKey derives a deterministic key from name.
+func sortitionKey(name string) ed25519.PrivateKey {
+	seed := sha256.Sum256([]byte(name))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+// ticketFor proves key's ticket for seed with the given stake.
+func ticketFor(key ed25519.PrivateKey, seed []byte, stake int) Ticket {
+	pub := key.Public().(ed25519.PublicKey)
+	proof, _ := ProveSortition(key, seed)
+	return Ticket{
+		Validator: AddressFromKey(pub),
+		PublicKey: hex.EncodeToString(pub),
+		Proof:     hex.EncodeToString(proof),
+		Stake:     stake,
+	}
+}
+
+func TestVRFTestVectors(t *testing.T) {
+	// RFC 9381, appendix B.3, examples 16 to 18.
+	tests := []struct {
+		sk, pk, alpha, pi, beta string
+	}{
+		{
+			sk:    "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60",
+			pk:    "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+			alpha: "",
+			pi:    "8657106690b5526245a92b003bb079ccd1a92130477671f6fc01ad16f26f723f26f8a57ccaed74ee1b190bed1f479d9727d2d0f9b005a6e456a35d4fb0daab1268a1b0db10836d9826a528ca76567805",
+			beta:  "90cf1df3b703cce59e2a35b925d411164068269d7b2d29f3301c03dd757876ff66b71dda49d2de59d03450451af026798e8f81cd2e333de5cdf4f3e140fdd8ae",
+		},
+		{
+			sk:    "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb",
+			pk:    "3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c",
+			alpha: "72",
+			pi:    "f3141cd382dc42909d19ec5110469e4feae18300e94f304590abdced48aed5933bf0864a62558b3ed7f2fea45c92a465301b3bbf5e3e54ddf2d935be3b67926da3ef39226bbc355bdc9850112c8f4b02",
+			beta:  "eb4440665d3891d668e7e0fcaf587f1b4bd7fbfe99d0eb2211ccec90496310eb5e33821bc613efb94db5e5b54c70a848a0bef4553a41befc57663b56373a5031",
+		},
+		{
+			sk:    "c5aa8df43f9f837bedb7442f31dcb7b166d38535076f094b85ce3a2e0b4458f7",
+			pk:    "fc51cd8e6218a1a38da47ed00230f0580816ed13ba3303ac5deb911548908025",
+			alpha: "af82",
+			pi:    "9bc0f79119cc5604bf02d23b4caede71393cedfbb191434dd016d30177ccbf8096bb474e53895c362d8628ee9f9ea3c0e52c7a5c691b6c18c9979866568add7a2d41b00b05081ed0f58ee5e31b3a970e",
+			beta:  "645427e5d00c62a23fb703732fa5d892940935942101e456ecca7bb217c61c452118fec1219202a0edcf038bb6373241578be7217ba85a2687f7a0310b2df19f",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pk[:8], func(t *testing.T) {
+			seed, _ := hex.DecodeString(tt.sk)
+			alpha, _ := hex.DecodeString(tt.alpha)
+			key := ed25519.NewKeyFromSeed(seed)
+			pub := key.Public().(ed25519.PublicKey)
+			if hex.EncodeToString(pub) != tt.pk {
+				t.Fatalf("public key %x, want %s", pub, tt.pk)
+			}
+			proof, output := vrfProve(key, alpha)
+			if hex.EncodeToString(proof) != tt.pi {
+				t.Fatalf("proof %x, want %s", proof, tt.pi)
+			}
+			if hex.EncodeToString(output) != tt.beta {
+				t.Fatalf("output %x, want %s", output, tt.beta)
+			}
+			if verified, ok := vrfVerify(pub, alpha, proof); !ok || !bytes.Equal(verified, output) {
+				t.Fatal("test vector does not verify")
+			}
+		})
+	}
+}
+
+// TestVRFVerifyEncodings checks that proofs and keys are only accepted in
+// their canonical encoding, and that keys of small order are refused.
+func TestVRFVerifyEncodings(t *testing.T) {
+	key := sortitionKey("alice")
+	pub := key.Public().(ed25519.PublicKey)
+	alpha := []byte("alpha")
+	proof, _ := vrfProve(key, alpha)
+
+	with := func(edit func(p []byte)) []byte {
+		p := append([]byte(nil), proof...)
+		edit(p)
+		return p
+	}
+	// s + L encodes the same scalar non-canonically.
+	order, _ := new(big.Int).SetString("7237005577332262213973186563042994240857116359379907606001950938285454250989", 10)
+	sPlusL := with(func(p []byte) {
+		s := new(big.Int).SetBytes(reversed(p[48:]))
+		s.Add(s, order)
+		copy(p[48:], reversed(s.FillBytes(make([]byte, 32))))
+	})
+	identity := make([]byte, 32)
+	identity[0] = 1
+	negativeZero := append([]byte(nil), identity...)
+	negativeZero[31] |= 0x80
+	// y = p+1 is a non-canonical encoding of the identity.
+	yAboveP := append(bytes.Repeat([]byte{0xff}, 31), 0x7f)
+	yAboveP[0] = 0xee
+
+	tests := []struct {
+		name  string
+		pub   []byte
+		proof []byte
+		ok    bool
+	}{
+		{"canonical", pub, proof, true},
+		{"scalar plus the group order", pub, sPlusL, false},
+		{"scalar all ones", pub, with(func(p []byte) { copy(p[48:], bytes.Repeat([]byte{0xff}, 32)) }), false},
+		{"gamma with its sign flipped", pub, with(func(p []byte) { p[31] ^= 0x80 }), false},
+		{"gamma the identity", pub, with(func(p []byte) { copy(p, identity) }), false},
+		{"gamma with y above p", pub, with(func(p []byte) { copy(p, yAboveP) }), false},
+		{"challenge flipped", pub, with(func(p []byte) { p[32] ^= 1 }), false},
+		{"identity key", identity, proof, false},
+		{"negative zero key", negativeZero, proof, false},
+		{"key with y above p", yAboveP, proof, false},
+		{"short key", pub[:31], proof, false},
+		{"long proof", pub, append(append([]byte(nil), proof...), 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := vrfVerify(tt.pub, alpha, tt.proof); ok != tt.ok {
+				t.Fatalf("verified %v, want %v", ok, tt.ok)
+			}
+		})
+	}
+}
+
+// reversed returns b in the opposite byte order.
+func reversed(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		out[len(b)-1-i] = c
+	}
+	return out
+}
+
+func TestVerifySortition(t *testing.T) {
+	key := sortitionKey("alice")
+	pub := key.Public().(ed25519.PublicKey)
+	seed := SortitionSeed("prev", 7)
+	proof, output := ProveSortition(key, seed)
+
+	tampered := append([]byte(nil), proof...)
+	tampered[len(tampered)-1] ^= 1
+	otherGamma := append([]byte(nil), proof...)
+	otherProof, _ := ProveSortition(key, SortitionSeed("prev", 8))
+	copy(otherGamma, otherProof[:32])
+	signature := ed25519.Sign(key, SortitionPayload(seed))
+
+	tests := []struct {
+		name  string
+		pub   ed25519.PublicKey
+		seed  []byte
+		proof []byte
+		ok    bool
+	}{
+		{"round trip", pub, seed, proof, true},
+		{"tampered scalar", pub, seed, tampered, false},
+		{"gamma of another round", pub, seed, otherGamma, false},
+		{"wrong seed", pub, SortitionSeed("prev", 8), proof, false},
+		{"wrong key", sortitionKey("bob").Public().(ed25519.PublicKey), seed, proof, false},
+		{"signature", pub, seed, signature, false},
+		{"truncated", pub, seed, proof[:len(proof)-1], false},
+		{"empty", pub, seed, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := VerifySortition(tt.pub, tt.seed, tt.proof)
+			if ok != tt.ok {
+				t.Fatalf("verified %v, want %v", ok, tt.ok)
+			}
+			if ok && !bytes.Equal(got, output) {
+				t.Fatalf("output %x, the prover got %x", got, output)
+			}
+		})
+	}
+
+	again, _ := ProveSortition(key, seed)
+	if !bytes.Equal(again, proof) {
+		t.Fatal("proving the same seed twice gave two proofs")
+	}
+}
+
+func TestSortitionThresholds(t *testing.T) {
+	seed := SortitionSeed("prev", 1)
+	var tickets []Ticket
+	for _, name := range []string{"a", "b", "c", "d"} {
+		tickets = append(tickets, ticketFor(sortitionKey(name), seed, 0))
+	}
+	withStakes := func(size float64, stakes ...int) SortitionRecord {
+		record := SortitionRecord{Seed: hex.EncodeToString(seed), Size: size}
+		for i, stake := range stakes {
+			ticket := tickets[i]
+			ticket.Stake = stake
+			record.Tickets = append(record.Tickets, ticket)
+			record.TotalStake += stake
+		}
+		return record
+	}
+
+	tests := []struct {
+		name   string
+		record SortitionRecord
+		// selected is the number of tickets selected, or -1 to only check
+		// who may be.
+		selected int
+		never    []int
+	}{
+		{"size covers every stake", withStakes(4, 10, 10, 10, 10), 4, nil},
+		{"whole stake passes", withStakes(1, 0, 50, 0, 0), 1, []int{0, 2, 3}},
+		{"no stake", withStakes(2, 0, 0, 0, 0), 0, nil},
+		{"zero stake never selected", withStakes(2, 0, 30, 0, 30), -1, []int{0, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := tt.record.Verify()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.selected >= 0 && len(selected) != tt.selected {
+				t.Fatalf("%d selected, want %d", len(selected), tt.selected)
+			}
+			for i, draw := range selected {
+				if i > 0 && draw.Priority() < selected[i-1].Priority() {
+					t.Fatalf("selected tickets are not in priority order")
+				}
+				for _, j := range tt.never {
+					if draw.Validator == tickets[j].Validator {
+						t.Fatalf("ticket %d selected without stake", j)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSortitionRecordRejects(t *testing.T) {
+	seed := SortitionSeed("prev", 1)
+	good := ticketFor(sortitionKey("a"), seed, 10)
+	foreign := ticketFor(sortitionKey("b"), seed, 10)
+	foreign.Validator = good.Validator
+	stale := ticketFor(sortitionKey("a"), SortitionSeed("prev", 2), 10)
+
+	tests := []struct {
+		name   string
+		ticket Ticket
+		total  int
+	}{
+		{"stake does not add up", good, 20},
+		{"key of another address", foreign, 10},
+		{"proof of another round", stale, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := SortitionRecord{Seed: hex.EncodeToString(seed), Size: 1, TotalStake: tt.total, Tickets: []Ticket{tt.ticket}}
+			if _, err := record.Verify(); err == nil {
+				t.Fatal("record verified")
+			}
+		})
+	}
+	record := SortitionRecord{Seed: hex.EncodeToString(seed), Size: 1, TotalStake: 10, Tickets: []Ticket{stale}}
+	if _, err := record.Verify(); !errors.Is(err, ErrBadProof) {
+		t.Fatalf("error %v, want %v", err, ErrBadProof)
+	}
+}
+
+func TestVerifyBlockSortition(t *testing.T) {
+	seed := SortitionSeed("prev", 3)
+	record := &SortitionRecord{Seed: hex.EncodeToString(seed), Size: 3}
+	for _, name := range []string{"a", "b", "c"} {
+		record.Tickets = append(record.Tickets, ticketFor(sortitionKey(name), seed, 10))
+		record.TotalStake += 10
+	}
+	selected, err := record.Verify()
+	if err != nil || len(selected) != 3 {
+		t.Fatalf("%d selected, error %v", len(selected), err)
+	}
+	leader, second := selected[0].Validator, selected[1].Validator
+
+	tests := []struct {
+		name      string
+		prevHash  string
+		round     int
+		validator string
+		committee []string
+		ok        bool
+	}{
+		{"leader", "prev", 3, leader, nil, true},
+		{"committee", "prev", 3, leader, []string{leader, second}, true},
+		{"not the leader", "prev", 3, second, nil, false},
+		{"committee out of order", "prev", 3, leader, []string{second, leader}, false},
+		{"other previous block", "other", 3, leader, nil, false},
+		{"other round", "prev", 4, leader, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block := Block{Round: tt.round, Validator: tt.validator, Committee: tt.committee, Sortition: record}
+			if err := VerifyBlockSortition(block, tt.prevHash); (err == nil) != tt.ok {
+				t.Fatalf("error %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package engine
+
+import "errors"
+
+var (
+	// ErrBondingDisabled is returned for bond and unbond requests when the
+	// run does not select by bonded stake.
+	ErrBondingDisabled = errors.New("server does not use bonded stake")
+	// ErrInsufficientStake is returned when a validator unbonds more than
+	// it has bonded.
+	ErrInsufficientStake = errors.New("unbond is more than your bonded stake")
+)
+
+// Unbonding is stake on its way out of the bond. It stays locked, and keeps
+// counting toward the validator's holdings but not its selection weight,
+// until round Release has settled.
+type Unbonding struct {
+	Amount  int
+	Release int
+}
+
+// Bonding makes selection depend on locked stake rather than bids or liquid
+// balances. Validators bond part of their balance; unbonded stake is released
+// UnbondingRounds rounds later.
+type Bonding struct {
+	Enabled         bool
+	UnbondingRounds int
+}
+
+// Bond moves amount from a validator's balance into its bonded stake.
+func (r *Registry) Bond(address string, amount int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, ok := r.nodes[address]
+	if !ok {
+		return ErrUnknownValidator
+	}
+	if node.Balance < amount {
+		return ErrInsufficientBalance
+	}
+	node.Balance -= amount
+	node.Bonded += amount
+	return nil
+}
+
+// Unbond moves amount of a validator's bonded stake into its unbonding
+// queue, to be released once round release has settled.
+func (r *Registry) Unbond(address string, amount, release int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, ok := r.nodes[address]
+	if !ok {
+		return ErrUnknownValidator
+	}
+	if node.Bonded < amount {
+		return ErrInsufficientStake
+	}
+	node.Bonded -= amount
+	node.Unbonding = append(node.Unbonding, Unbonding{Amount: amount, Release: release})
+	return nil
+}
+
+// ReleaseUnbonded returns every unbonding entry due by round to its
+// validator's balance.
+func (r *Registry) ReleaseUnbonded(round int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, node := range r.nodes {
+		kept := node.Unbonding[:0]
+		for _, entry := range node.Unbonding {
+			if entry.Release <= round {
+				node.Balance += entry.Amount
+				continue
+			}
+			kept = append(kept, entry)
+		}
+		node.Unbonding = kept
+	}
+}
+
+// Stake returns a validator's bonded stake and the amount still unbonding.
+func (r *Registry) Stake(address string) (bonded, unbonding int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, ok := r.nodes[address]
+	if !ok {
+		return 0, 0
+	}
+	for _, entry := range node.Unbonding {
+		unbonding += entry.Amount
+	}
+	return node.Bonded, unbonding
+}
+
+// stake handles a BondStake or UnbondStake request, and passes delegation
+// requests on to delegate.
+func (m *RoundManager) stake(req BidRequest) error {
+	if !m.economics.Bonding.Enabled {
+		return ErrBondingDisabled
+	}
+	if err := m.registry.Authorize(req); err != nil {
+		return err
+	}
+	if req.Kind != BondStake && req.Kind != UnbondStake {
+		return m.delegate(req)
+	}
+	if req.Bid <= 0 {
+		return ErrNonPositiveBid
+	}
+	if req.Kind == BondStake {
+		return m.registry.Bond(req.Address, req.Bid)
+	}
+	return m.registry.Unbond(req.Address, req.Bid, m.round+m.economics.Bonding.UnbondingRounds)
+}
+
+// weights returns the bonded plus delegated stake of every bidder, or nil
+// when selection does not use bonded stake.
+func (m *RoundManager) weights() map[string]int {
+	if !m.economics.Bonding.Enabled {
+		return nil
+	}
+	delegated := m.registry.Delegated()
+	weights := make(map[string]int)
+	for _, bidItem := range m.bids {
+		bonded, _ := m.registry.Stake(bidItem.NodeAddress)
+		weights[bidItem.NodeAddress] = bonded + delegated[bidItem.NodeAddress]
+	}
+	return weights
+}
@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrMalformedBid is returned when a bid cannot be parsed.
+	ErrMalformedBid = errors.New("bid is malformed")
+	// ErrNonPositiveBid is returned for bids of zero or less.
+	ErrNonPositiveBid = errors.New("bid must be positive")
+	// ErrBidAboveCap is returned for bids above BidRules.MaxBid.
+	ErrBidAboveCap = errors.New("bid is above the cap")
+	// ErrBidGranularity is returned for bids that are not a multiple of
+	// BidRules.Granularity.
+	ErrBidGranularity = errors.New("bid is not a multiple of the bid granularity")
+	// ErrBidIncrement is returned for open bids that do not beat the
+	// round's best bid by BidRules.MinIncrement.
+	ErrBidIncrement = errors.New("bid does not beat the best bid by the minimum increment")
+	// ErrDuplicateBid is returned when a validator bids twice in one round.
+	ErrDuplicateBid = errors.New("validator already bid this round")
+)
+
+// BidRules are the limits every bid must respect on top of the bidder's
+// balance. Zero values disable a rule.
+type BidRules struct {
+	// MaxBid caps a single bid.
+	MaxBid int
+	// Granularity is the unit bids are counted in: every bid must be a
+	// multiple of it. It does not require a bid to beat any other.
+	Granularity int
+	// MinIncrement is how much a bid must beat the round's best bid so far
+	// by. It only applies to open bids: sealed bids are not seen until the
+	// round has closed.
+	MinIncrement int
+	// Late decides what happens to bids that arrive while no round is open.
+	Late LatePolicy
+	// Reserve sets the minimum bid of each round.
+	Reserve ReservePolicy
+}
+
+// Check validates the amount of a bid. Balance, one-bid-per-round and the
+// minimum increment are checked by the RoundManager, which knows the round's
+// state.
+func (r BidRules) Check(bid int) error {
+	if bid <= 0 {
+		return ErrNonPositiveBid
+	}
+	if r.Granularity > 1 && bid%r.Granularity != 0 {
+		return ErrBidGranularity
+	}
+	if r.MaxBid > 0 && bid > r.MaxBid {
+		return ErrBidAboveCap
+	}
+	return nil
+}
+
+// RoundStats counts the bids a round accepted and, by reason code, the bids
+// it rejected. Carried counts the accepted bids that arrived late for the
+// previous round. In sealed-bid rounds Accepted counts commitments, Revealed
+// the ones that were opened and Forfeited the ones that were not.
+type RoundStats struct {
+	Accepted  int `json:"accepted"`
+	Carried   int `json:"carried,omitempty"`
+	Revealed  int `json:"revealed,omitempty"`
+	Forfeited int `json:"forfeited,omitempty"`
+	// Revenue is the total charged at settlement: payments and forfeits.
+	Revenue int `json:"revenue"`
+	// Burned and Minted are the tokens taken out of and put into
+	// circulation by Economics.
+	Burned int `json:"burned,omitempty"`
+	Minted int `json:"minted,omitempty"`
+	// Slashed is the stake destroyed by slashing.
+	Slashed    int            `json:"slashed,omitempty"`
+	Rejections map[string]int `json:"rejections,omitempty"`
+}
+
+// reject counts err under its reason code.
+func (s *RoundStats) reject(err error) {
+	if s.Rejections == nil {
+		s.Rejections = make(map[string]int)
+	}
+	s.Rejections[rejectReason(err)]++
+}
+
+// Rejected returns the total number of rejected bids.
+func (s RoundStats) Rejected() int {
+	total := 0
+	for _, n := range s.Rejections {
+		total += n
+	}
+	return total
+}
+
+// add accumulates other into s.
+func (s *RoundStats) add(other RoundStats) {
+	s.Accepted += other.Accepted
+	s.Carried += other.Carried
+	s.Revealed += other.Revealed
+	s.Forfeited += other.Forfeited
+	s.Revenue += other.Revenue
+	s.Burned += other.Burned
+	s.Minted += other.Minted
+	s.Slashed += other.Slashed
+	for reason, n := range other.Rejections {
+		if s.Rejections == nil {
+			s.Rejections = make(map[string]int)
+		}
+		s.Rejections[reason] += n
+	}
+}
+
+func (s RoundStats) String() string {
+	out := fmt.Sprintf("%d bids accepted, %d rejected", s.Accepted, s.Rejected())
+	if s.Carried > 0 {
+		out = fmt.Sprintf("%d bids accepted (%d carried over), %d rejected", s.Accepted, s.Carried, s.Rejected())
+	}
+	if s.Revealed > 0 || s.Forfeited > 0 {
+		out += fmt.Sprintf(", %d revealed, %d forfeited", s.Revealed, s.Forfeited)
+	}
+	if len(s.Rejections) == 0 {
+		return out
+	}
+	reasons := make([]string, 0, len(s.Rejections))
+	for reason, n := range s.Rejections {
+		reasons = append(reasons, fmt.Sprintf("%s=%d", reason, n))
+	}
+	sort.Strings(reasons)
+	return out + " (" + strings.Join(reasons, ", ") + ")"
+}
@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestBidRulesCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules BidRules
+		bid   int
+		want  error
+		code  string
+	}{
+		{"no rules", BidRules{}, 7, nil, ""},
+		{"zero", BidRules{}, 0, ErrNonPositiveBid, "non_positive_bid"},
+		{"negative", BidRules{MaxBid: 10}, -5, ErrNonPositiveBid, "non_positive_bid"},
+		{"multiple of the granularity", BidRules{Granularity: 5}, 15, nil, ""},
+		{"off the granularity", BidRules{Granularity: 5}, 12, ErrBidGranularity, "bad_granularity"},
+		{"granularity one accepts anything", BidRules{Granularity: 1}, 13, nil, ""},
+		{"at the cap", BidRules{MaxBid: 100}, 100, nil, ""},
+		{"above the cap", BidRules{MaxBid: 100}, 101, ErrBidAboveCap, "above_cap"},
+		{"granularity before the cap", BidRules{MaxBid: 100, Granularity: 10}, 105, ErrBidGranularity, "bad_granularity"},
+		{"on the granularity above the cap", BidRules{MaxBid: 100, Granularity: 10}, 110, ErrBidAboveCap, "above_cap"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rules.Check(tt.bid)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error %v, want %v", err, tt.want)
+			}
+			if err != nil && rejectReason(err) != tt.code {
+				t.Fatalf("reason %q, want %q", rejectReason(err), tt.code)
+			}
+		})
+	}
+}
+
+func TestSubmitBidRejectionCodes(t *testing.T) {
+	rules := BidRules{MaxBid: 500, Granularity: 5, Reserve: ReservePolicy{Mode: StaticReserve, Price: 20}}
+	rounds, _, key, address := testRounds(t, NewVickreyLottery(), rules, Economics{})
+
+	tests := []struct {
+		name string
+		req  BidRequest
+		code string
+	}{
+		{"below the reserve", BidRequest{BPM: 70, Bid: 10}, "below_reserve"},
+		{"off the granularity", BidRequest{BPM: 70, Bid: 22}, "bad_granularity"},
+		{"above the cap", BidRequest{BPM: 70, Bid: 505}, "above_cap"},
+		{"accepted", BidRequest{BPM: 70, Bid: 25}, ""},
+		{"retransmitted", BidRequest{BPM: 70, Bid: 25}, "duplicate_bid"},
+	}
+	for i, tt := range tests {
+		_, err := rounds.SubmitBid(signed(key, address, 1, uint64(i)+1, tt.req))
+		code := ""
+		if err != nil {
+			code = rejectReason(err)
+		}
+		if code != tt.code {
+			t.Fatalf("%s: reason %q (%v), want %q", tt.name, code, err, tt.code)
+		}
+	}
+}
+
+func TestMinIncrement(t *testing.T) {
+	tests := []struct {
+		name      string
+		increment int
+		// bids are submitted in turn by validators b0, b1, ...; want holds
+		// each one's reason code.
+		bids []int
+		want []string
+	}{
+		{"no minimum", 0, []int{50, 50, 10}, []string{"", "", ""}},
+		{"first bid is free", 10, []int{5}, []string{""}},
+		{"beats the best by the increment", 10, []int{50, 60, 75}, []string{"", "", ""}},
+		{"short of the increment", 10, []int{50, 59}, []string{"", "below_increment"}},
+		{"measured against the best, not the last", 10, []int{50, 80, 70, 89}, []string{"", "", "below_increment", "below_increment"}},
+		{"equal bid", 1, []int{50, 50}, []string{"", "below_increment"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rounds, registry, _, _ := testRounds(t, NewVickreyLottery(), BidRules{MinIncrement: tt.increment}, Economics{})
+			for i, bid := range tt.bids {
+				address := "b" + strconv.Itoa(i)
+				registry.Register(address, 1000)
+				_, err := rounds.SubmitBid(BidRequest{Address: address, BPM: 70, Bid: bid})
+				code := ""
+				if err != nil {
+					code = rejectReason(err)
+				}
+				if code != tt.want[i] {
+					t.Fatalf("bid %d of %d: reason %q (%v), want %q", i, bid, code, err, tt.want[i])
+				}
+				if balance, _ := registry.Balance(address); code != "" && balance != 1000 {
+					t.Fatalf("rejected bid %d escrowed %d", bid, 1000-balance)
+				}
+			}
+		})
+	}
+}
+
+// TestMinIncrementSealed checks that sealed bids are not held to the
+// minimum increment, which nobody could know to meet.
+func TestMinIncrementSealed(t *testing.T) {
+	rounds, registry, _, _ := testRounds(t, NewCommitReveal(NewVickreyLottery(), 0), BidRules{MinIncrement: 10}, Economics{})
+	salt := []byte("salt")
+	for i, bid := range []int{50, 50} {
+		address := "b" + strconv.Itoa(i)
+		registry.Register(address, 1000)
+		if _, err := rounds.SubmitBid(BidRequest{Address: address, Round: 1, Kind: CommitBid, BPM: 70, Commitment: Commitment(address, 1, bid, salt)}); err != nil {
+			t.Fatalf("commit %s: %v", address, err)
+		}
+	}
+	rounds.Close()
+	for i := range []int{50, 50} {
+		address := "b" + strconv.Itoa(i)
+		if _, err := rounds.SubmitBid(BidRequest{Address: address, Round: 1, Kind: RevealBid, Bid: 50, Salt: salt}); err != nil {
+			t.Fatalf("reveal %s: %v", address, err)
+		}
+	}
+}
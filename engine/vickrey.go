@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// VickreyLottery picks the winner with a bid-weighted lottery, or one weighted
+// by Round.Weights when set, and charges it the second highest bid of the
+// round. Every other bid is refunded. Where the
+// payment goes is decided by the run's Economics.
+type VickreyLottery struct{}
+
+// NewVickreyLottery returns the Vickrey auction mechanism used by the
+// Vick and Vic_gen variants.
+func NewVickreyLottery() *VickreyLottery {
+	return &VickreyLottery{}
+}
+
+// Name implements SelectionMechanism.
+func (v *VickreyLottery) Name() string {
+	return "vickrey"
+}
+
+// Select implements SelectionMechanism.
+func (v *VickreyLottery) Select(round Round, rng *rand.Rand) Outcome {
+	outcome := v.SelectCommittee(round, 1, rng)
+	outcome.Committee = nil
+	return outcome
+}
+
+// SelectCommittee draws k distinct bidders by the same lottery, without
+// replacement. Every member pays the highest bid outside the committee,
+// capped at its own bid; for k = 1 that is Select's second price.
+func (v *VickreyLottery) SelectCommittee(round Round, k int, rng *rand.Rand) Outcome {
+	if len(round.Candidates) == 0 || len(round.Bids) == 0 {
+		return Outcome{}
+	}
+
+	bids := make(map[string]int)
+	for _, bidItem := range round.Bids {
+		if bidItem.Bid <= 0 {
+			continue
+		}
+		bids[bidItem.NodeAddress] += bidItem.Bid
+	}
+	weights := bids
+	if round.Weights != nil {
+		weights = make(map[string]int, len(bids))
+		for addr := range bids {
+			weights[addr] = round.Weights[addr]
+		}
+	}
+
+	committee := weightedSample(weights, k, rng)
+	if len(committee) == 0 {
+		return Outcome{}
+	}
+
+	secondPrice := withReserve(highestBidOutside(bids, memberSet(committee)), round.Reserve)
+	outcome := Outcome{Winner: committee[0], Committee: committee, Payments: make(map[string]int, len(committee))}
+	for _, member := range committee {
+		price := secondPrice
+		if price > bids[member] {
+			price = bids[member]
+		}
+		outcome.Payments[member] = price
+	}
+	outcome.Price = outcome.Payments[outcome.Winner]
+	return outcome
+}
+
+// highestBidOutside returns the highest bid of a validator not in members.
+func highestBidOutside(weights map[string]int, members map[string]bool) int {
+	ordered := make([]BidItem, 0, len(weights))
+	for addr, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		ordered = append(ordered, BidItem{NodeAddress: addr, Bid: weight})
+	}
+
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].Bid > ordered[j].Bid
+	})
+
+	for _, item := range ordered {
+		if members[item.NodeAddress] {
+			continue
+		}
+		return item.Bid
+	}
+
+	return 0
+}
@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+
+	"filippo.io/edwards25519"
+)
+
+// This file implements ECVRF-EDWARDS25519-SHA512-TAI from RFC 9381, the
+// verifiable random function sortition runs on. It works on the Ed25519 keys
+// validators already register with: the VRF secret scalar and nonce key are
+// derived from the private key seed as RFC 8032 derives them, so the VRF
+// public key is the Ed25519 public key. The group arithmetic is
+// filippo.io/edwards25519, the implementation behind crypto/ed25519; only the
+// VRF construction is done here.
+
+// vrfSuite is the suite string of ECVRF-EDWARDS25519-SHA512-TAI.
+const vrfSuite = 0x03
+
+const (
+	// vrfChallengeSize is the size of the challenge c in a proof.
+	vrfChallengeSize = 16
+	// vrfProofSize is the size of a proof: the point Gamma, the challenge
+	// c and the scalar s.
+	vrfProofSize = 32 + vrfChallengeSize + 32
+	// VRFOutputSize is the size of a VRF output.
+	VRFOutputSize = sha512.Size
+)
+
+// decodePoint is string_to_point: RFC 8032 decoding, which unlike SetBytes
+// rejects non-canonical encodings.
+func decodePoint(s []byte) (*edwards25519.Point, bool) {
+	p, err := new(edwards25519.Point).SetBytes(s)
+	if err != nil || !bytes.Equal(p.Bytes(), s) {
+		return nil, false
+	}
+	return p, true
+}
+
+// smallOrder reports whether p is in the subgroup of order 8.
+func smallOrder(p *edwards25519.Point) bool {
+	return new(edwards25519.Point).MultByCofactor(p).Equal(edwards25519.NewIdentityPoint()) == 1
+}
+
+// vrfHashToCurve is ECVRF_encode_to_curve_try_and_increment: it hashes the
+// public key and alpha with a counter until the digest decodes to a point,
+// and clears its cofactor.
+func vrfHashToCurve(pub, alpha []byte) *edwards25519.Point {
+	for ctr := 0; ; ctr++ {
+		h := sha512.New()
+		h.Write([]byte{vrfSuite, 0x01})
+		h.Write(pub)
+		h.Write(alpha)
+		h.Write([]byte{byte(ctr), 0x00})
+		if p, ok := decodePoint(h.Sum(nil)[:32]); ok && !smallOrder(p) {
+			return p.MultByCofactor(p)
+		}
+	}
+}
+
+// vrfChallenge is ECVRF_challenge_generation: the first 16 bytes of the
+// hash of the five points.
+func vrfChallenge(points ...*edwards25519.Point) []byte {
+	h := sha512.New()
+	h.Write([]byte{vrfSuite, 0x02})
+	for _, p := range points {
+		h.Write(p.Bytes())
+	}
+	h.Write([]byte{0x00})
+	return h.Sum(nil)[:vrfChallengeSize]
+}
+
+// challengeScalar returns a challenge as a scalar. It is below 2^128, so
+// always canonical.
+func challengeScalar(c []byte) *edwards25519.Scalar {
+	var buf [32]byte
+	copy(buf[:], c)
+	s, _ := edwards25519.NewScalar().SetCanonicalBytes(buf[:])
+	return s
+}
+
+// vrfOutput is ECVRF_proof_to_hash.
+func vrfOutput(gamma *edwards25519.Point) []byte {
+	h := sha512.New()
+	h.Write([]byte{vrfSuite, 0x03})
+	h.Write(new(edwards25519.Point).MultByCofactor(gamma).Bytes())
+	h.Write([]byte{0x00})
+	return h.Sum(nil)
+}
+
+// vrfProve evaluates the VRF on alpha with an Ed25519 key and returns the
+// proof and output.
+func vrfProve(priv ed25519.PrivateKey, alpha []byte) (proof, output []byte) {
+	pub := priv.Public().(ed25519.PublicKey)
+	digest := sha512.Sum512(priv.Seed())
+	x, _ := edwards25519.NewScalar().SetBytesWithClamping(digest[:32])
+	y, _ := decodePoint(pub)
+
+	h := vrfHashToCurve(pub, alpha)
+	gamma := new(edwards25519.Point).ScalarMult(x, h)
+	nonce := sha512.New()
+	nonce.Write(digest[32:])
+	nonce.Write(h.Bytes())
+	k, _ := edwards25519.NewScalar().SetUniformBytes(nonce.Sum(nil))
+	c := vrfChallenge(y, h, gamma,
+		new(edwards25519.Point).ScalarBaseMult(k),
+		new(edwards25519.Point).ScalarMult(k, h))
+	s := edwards25519.NewScalar().MultiplyAdd(challengeScalar(c), x, k)
+
+	proof = append(gamma.Bytes(), c...)
+	proof = append(proof, s.Bytes()...)
+	return proof, vrfOutput(gamma)
+}
+
+// vrfVerify checks a proof of alpha against an Ed25519 public key and returns
+// its output. Keys of small order are rejected, so that no key can make its
+// output independent of alpha.
+func vrfVerify(pub ed25519.PublicKey, alpha, proof []byte) ([]byte, bool) {
+	if len(pub) != ed25519.PublicKeySize || len(proof) != vrfProofSize {
+		return nil, false
+	}
+	y, ok := decodePoint(pub)
+	if !ok || smallOrder(y) {
+		return nil, false
+	}
+	gamma, ok := decodePoint(proof[:32])
+	if !ok {
+		return nil, false
+	}
+	c := proof[32 : 32+vrfChallengeSize]
+	s, err := edwards25519.NewScalar().SetCanonicalBytes(proof[32+vrfChallengeSize:])
+	if err != nil {
+		return nil, false
+	}
+	negC := edwards25519.NewScalar().Negate(challengeScalar(c))
+	h := vrfHashToCurve(pub, alpha)
+	// U = s*B - c*Y and V = s*H - c*Gamma.
+	u := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(negC, y, s)
+	v := new(edwards25519.Point).VarTimeMultiScalarMult([]*edwards25519.Scalar{s, negC}, []*edwards25519.Point{h, gamma})
+	if !bytes.Equal(vrfChallenge(y, h, gamma, u, v), c) {
+		return nil, false
+	}
+	return vrfOutput(gamma), true
+}
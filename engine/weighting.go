@@ -0,0 +1,300 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// WeightingKind is one way of transforming a validator's selection weight.
+type WeightingKind int
+
+const (
+	// WeightCap limits every weight to Param.
+	WeightCap WeightingKind = iota + 1
+	// WeightSqrt replaces a weight by its square root.
+	WeightSqrt
+	// WeightQuadratic replaces a weight by its square.
+	WeightQuadratic
+	// WeightLog replaces a weight w by ln(1+w).
+	WeightLog
+	// WeightCoinAge multiplies a weight by the number of rounds since the
+	// validator last won, at most Param when it is set. Winning resets the
+	// age, as in Peercoin.
+	WeightCoinAge
+)
+
+var weightingKinds = map[string]WeightingKind{
+	"cap":       WeightCap,
+	"sqrt":      WeightSqrt,
+	"quadratic": WeightQuadratic,
+	"log":       WeightLog,
+	"coin-age":  WeightCoinAge,
+}
+
+// weightScale keeps the square root and logarithm of a weight in thousandths,
+// so that small weights keep their resolution as integers.
+const weightScale = 1000
+
+// maxWeightTotal bounds the sum of a round's transformed weights. Larger
+// sums are scaled down to it, which leaves room for the lottery to add them
+// up as ints.
+const maxWeightTotal = float64(math.MaxInt >> 1)
+
+// maxWeight is the largest transformed weight a policy may produce from the
+// largest int stake and age: a round of 2^32 such validators still adds up
+// to a finite float64.
+const maxWeight = math.MaxFloat64 / (1 << 32)
+
+// WeightTransform is one step of a WeightingPolicy.
+type WeightTransform struct {
+	Kind  WeightingKind
+	Param int
+}
+
+// WeightingPolicy is the chain of transforms applied, in order, to every
+// validator's weight before a lottery draws. The empty policy draws by the
+// weights the lottery would use anyway.
+type WeightingPolicy []WeightTransform
+
+// ParseWeightingPolicy parses "none" or a comma-separated chain of "cap:N",
+// "sqrt", "quadratic", "log" and "coin-age[:MAX]", e.g. "cap:500,sqrt".
+func ParseWeightingPolicy(spec string) (WeightingPolicy, error) {
+	if spec == "none" || spec == "" {
+		return nil, nil
+	}
+	var policy WeightingPolicy
+	for _, step := range strings.Split(spec, ",") {
+		name, param, hasParam := strings.Cut(step, ":")
+		kind, ok := weightingKinds[name]
+		if !ok {
+			return nil, fmt.Errorf("weighting %q: unknown transform %q (want cap:N, sqrt, quadratic, log or coin-age[:MAX])", spec, name)
+		}
+		transform := WeightTransform{Kind: kind}
+		switch {
+		case kind == WeightCap || kind == WeightCoinAge && hasParam:
+			n, err := strconv.Atoi(param)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("weighting %q: %s needs a positive integer, got %q", spec, name, param)
+			}
+			transform.Param = n
+		case hasParam:
+			return nil, fmt.Errorf("weighting %q: %s takes no parameter", spec, name)
+		}
+		policy = append(policy, transform)
+	}
+	if policy.apply(math.MaxInt, math.MaxInt) > maxWeight {
+		return nil, fmt.Errorf("weighting %q: weights of large stakes cannot be represented", spec)
+	}
+	return policy, nil
+}
+
+func (p WeightingPolicy) String() string {
+	if len(p) == 0 {
+		return "none"
+	}
+	steps := make([]string, len(p))
+	for i, transform := range p {
+		steps[i] = transform.String()
+	}
+	return strings.Join(steps, ",")
+}
+
+func (t WeightTransform) String() string {
+	for name, kind := range weightingKinds {
+		if kind != t.Kind {
+			continue
+		}
+		if t.Param > 0 {
+			return name + ":" + strconv.Itoa(t.Param)
+		}
+		return name
+	}
+	return "none"
+}
+
+// apply transforms weight, held by a validator that last won age rounds ago.
+// It works in float64 so that squares and ages cannot wrap around; every
+// transform is monotonic, so ParseWeightingPolicy bounds the result by
+// applying the policy to the largest stake and age.
+func (p WeightingPolicy) apply(weight, age float64) float64 {
+	for _, t := range p {
+		if weight <= 0 {
+			return 0
+		}
+		switch t.Kind {
+		case WeightCap:
+			weight = math.Min(weight, float64(t.Param))
+		case WeightSqrt:
+			weight = math.Trunc(math.Sqrt(weight) * weightScale)
+		case WeightQuadratic:
+			weight *= weight
+		case WeightLog:
+			weight = math.Trunc(math.Log1p(weight) * weightScale)
+		case WeightCoinAge:
+			if t.Param > 0 {
+				age = math.Min(age, float64(t.Param))
+			}
+			weight *= age
+		}
+	}
+	return weight
+}
+
+// ages reports whether the policy needs to know when validators last won.
+func (p WeightingPolicy) ages() bool {
+	for _, t := range p {
+		if t.Kind == WeightCoinAge {
+			return true
+		}
+	}
+	return false
+}
+
+// weightedLottery is a lottery whose draw weights can be replaced through
+// Round.Weights.
+type weightedLottery interface {
+	committeeLottery
+	SelectionMechanism
+	// lotteryWeights returns the weight the lottery would draw each
+	// proposer by in round.
+	lotteryWeights(round Round) map[string]int
+}
+
+// Weighted runs a lottery with every validator's weight transformed by a
+// WeightingPolicy first, so that the policies can be compared on the same
+// bids. It keeps the round each validator last won for coin-age weighting.
+type Weighted struct {
+	inner  weightedLottery
+	policy WeightingPolicy
+	rounds int
+	// lastWin is the round each validator last won in; validators that never
+	// won count their age from the start of the run.
+	lastWin map[string]int
+}
+
+// NewWeighted wraps inner, which must be one of the lotteries or sortition,
+// to draw by weights transformed under policy.
+func NewWeighted(inner SelectionMechanism, policy WeightingPolicy) (*Weighted, error) {
+	lottery, ok := inner.(weightedLottery)
+	if !ok {
+		return nil, fmt.Errorf("weighting %s: mechanism %s is not a lottery", policy, inner.Name())
+	}
+	return &Weighted{inner: lottery, policy: policy, lastWin: make(map[string]int)}, nil
+}
+
+func (w *Weighted) Name() string { return "weighted-" + w.inner.Name() }
+
+func (w *Weighted) sortition() bool { return usesSortition(w.inner) }
+
+// Select implements SelectionMechanism.
+func (w *Weighted) Select(round Round, rng *rand.Rand) Outcome {
+	return w.won(w.inner.Select(w.weigh(round), rng))
+}
+
+// SelectCommittee lets committees sample from the weighted lottery.
+func (w *Weighted) SelectCommittee(round Round, k int, rng *rand.Rand) Outcome {
+	return w.won(w.inner.SelectCommittee(w.weigh(round), k, rng))
+}
+
+// weigh starts a new round and replaces its weights by the transformed ones.
+// When they add up to more than maxWeightTotal they are all scaled down by
+// the same factor, which keeps the odds of every validator but the
+// negligible ones.
+func (w *Weighted) weigh(round Round) Round {
+	w.rounds++
+	values := make(map[string]float64)
+	total := 0.0
+	for addr, weight := range w.inner.lotteryWeights(round) {
+		values[addr] = w.policy.apply(float64(weight), float64(w.rounds-w.lastWin[addr]))
+		total += values[addr]
+	}
+	scale := 1.0
+	if total > maxWeightTotal {
+		scale = maxWeightTotal / total
+	}
+	round.Weights = make(map[string]int, len(values))
+	for addr, value := range values {
+		round.Weights[addr] = int(value * scale)
+	}
+	return round
+}
+
+// won resets the coin age of every member of the outcome.
+func (w *Weighted) won(outcome Outcome) Outcome {
+	if w.policy.ages() {
+		for _, member := range committeeOf(outcome) {
+			w.lastWin[member] = w.rounds
+		}
+	}
+	return outcome
+}
+
+func (v *VickreyLottery) lotteryWeights(round Round) map[string]int {
+	weights := make(map[string]int)
+	for _, bidItem := range round.Bids {
+		if bidItem.Bid <= 0 {
+			continue
+		}
+		if round.Weights != nil {
+			weights[bidItem.NodeAddress] = round.Weights[bidItem.NodeAddress]
+			continue
+		}
+		weights[bidItem.NodeAddress] += bidItem.Bid
+	}
+	return weights
+}
+
+func (l *BalanceLottery) lotteryWeights(round Round) map[string]int {
+	weights := make(map[string]int)
+	for _, block := range round.Candidates {
+		weights[block.Proposer] = round.Balances[block.Proposer]
+		if round.Weights != nil {
+			weights[block.Proposer] = round.Weights[block.Proposer]
+		}
+	}
+	return weights
+}
+
+func (s *Sortition) lotteryWeights(round Round) map[string]int {
+	weights := make(map[string]int)
+	for _, bidItem := range round.Bids {
+		weights[bidItem.NodeAddress] = round.Balances[bidItem.NodeAddress]
+		if round.Weights != nil {
+			weights[bidItem.NodeAddress] = round.Weights[bidItem.NodeAddress]
+		}
+	}
+	return weights
+}
+
+// WinSummary describes how the blocks of a run were shared out.
+type WinSummary struct {
+	Wins int
+	// TopShare is the share of the wins taken by the validator that won
+	// most, and Gini the Gini coefficient of the wins.
+	TopShare float64
+	Gini     float64
+}
+
+// SummarizeWins summarises the wins of every validator.
+func SummarizeWins(wins []int, gini GiniFunc) WinSummary {
+	summary := WinSummary{Gini: gini(wins)}
+	top := 0
+	for _, n := range wins {
+		summary.Wins += n
+		if n > top {
+			top = n
+		}
+	}
+	if summary.Wins > 0 {
+		summary.TopShare = float64(top) / float64(summary.Wins)
+	}
+	return summary
+}
+
+func (s WinSummary) String() string {
+	return fmt.Sprintf("%d wins; the top validator took %.1f%%, Gini coefficient of wins %v",
+		s.Wins, 100*s.TopShare, s.Gini)
+}
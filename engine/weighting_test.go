@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestWeightingLargeBalances(t *testing.T) {
+	balances := []int{2e9, 4e9, math.MaxInt / 4}
+	round := Round{Balances: make(map[string]int)}
+	for i, balance := range balances {
+		addr := string(rune('a' + i))
+		round.Candidates = append(round.Candidates, Block{Proposer: addr})
+		round.Balances[addr] = balance
+	}
+
+	for _, spec := range []string{"cap:1000000000", "sqrt", "quadratic", "log", "coin-age", "coin-age:5", "quadratic,coin-age", "log,quadratic"} {
+		t.Run(spec, func(t *testing.T) {
+			policy, err := ParseWeightingPolicy(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			weighted, err := NewWeighted(NewBalanceLottery(), policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rng := rand.New(rand.NewSource(1))
+			for i := 0; i < 20; i++ {
+				// Select, one step at a time.
+				weightedRound := weighted.weigh(round)
+				weights := weightedRound.Weights
+				total := 0
+				for j := 1; j < len(balances); j++ {
+					prev, next := weights[string(rune('a'+j-1))], weights[string(rune('a'+j))]
+					if i == 0 && next < prev {
+						t.Fatalf("weights %v do not keep the order of the balances", weights)
+					}
+				}
+				for addr, weight := range weights {
+					if weight < 0 || total > math.MaxInt-weight {
+						t.Fatalf("round %d: weight of %s is %d after a total of %d", i, addr, weight, total)
+					}
+					total += weight
+				}
+				if outcome := weighted.won(weighted.inner.Select(weightedRound, rng)); outcome.Winner == "" {
+					t.Fatalf("round %d: no winner among %v", i, weights)
+				}
+			}
+		})
+	}
+}
+
+func TestWeightingUnrepresentable(t *testing.T) {
+	if _, err := ParseWeightingPolicy("quadratic,quadratic,quadratic,quadratic"); err == nil {
+		t.Fatal("squaring four times was accepted")
+	}
+	if _, err := ParseWeightingPolicy("quadratic,quadratic,quadratic"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,11 +3,14 @@ module simulation
 go 1.20
 
 require (
+	filippo.io/edwards25519 v1.1.0
 	github.com/davecgh/go-spew v0.0.0
 	github.com/joho/godotenv v0.0.0
 	github.com/tealeg/xlsx v0.0.0
 )
 
+replace filippo.io/edwards25519 => ./vendor/filippo.io/edwards25519
+
 replace github.com/davecgh/go-spew => ./vendor/github.com/davecgh/go-spew
 
 replace github.com/joho/godotenv => ./vendor/github.com/joho/godotenv
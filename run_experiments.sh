@@ -14,6 +14,8 @@ DEFAULT_BALANCE=1000
 DEFAULT_BASE_COST=15
 DEFAULT_ROUND=60
 DEFAULT_INTER_DELAY=1
+DEFAULT_SEED=""
+DEFAULT_POPULATION=""
 
 variants=()
 duration="$DEFAULT_DURATION"
@@ -22,6 +24,8 @@ balance="$DEFAULT_BALANCE"
 base_cost="$DEFAULT_BASE_COST"
 round_interval="$DEFAULT_ROUND"
 inter_delay="$DEFAULT_INTER_DELAY"
+seed="$DEFAULT_SEED"
+population="$DEFAULT_POPULATION"
 
 function usage() {
 	cat <<'EOS'
@@ -35,6 +39,8 @@ Options:
   --base-cost COST    Base bid cost used by simulated validators (default: 15).
   --round SECONDS     Interval between bids from each validator (default: 60).
   --inter-delay SEC   Delay between BPM and bid submissions (default: 1).
+  --seed N            Seed for the server lottery and the client simulator (default: time based).
+  --population FILE   JSON population file for the client simulator (overrides --clients/--balance).
   --help              Show this help message.
 
 Environment overrides:
@@ -80,6 +86,14 @@ while [[ $# -gt 0 ]]; do
 			inter_delay="$2"
 			shift 2
 			;;
+		--seed)
+			seed="$2"
+			shift 2
+			;;
+		--population)
+			population="$(cd "$(dirname "$2")" && pwd)/$(basename "$2")"
+			shift 2
+			;;
 		--help)
 			usage
 			exit 0
@@ -194,6 +208,7 @@ run_variant() {
 	local server_log="$ARTIFACT_DIR/${timestamp}_${variant}_server.log"
 	local client_log="$ARTIFACT_DIR/${timestamp}_${variant}_clients.log"
 	local chain_snapshot="$ARTIFACT_DIR/${timestamp}_${variant}_blockchain.txt"
+	local genesis_file="$ARTIFACT_DIR/${timestamp}_${variant}_genesis.json"
 
 	echo "=== Running $variant on $host:$port ==="
 	echo "Server log:   $server_log"
@@ -202,9 +217,35 @@ run_variant() {
 	: >"$server_log"
 	: >"$client_log"
 
+	# The client and the genesis file must derive the same validator keys, so
+	# a run without --seed still uses one seed for both.
+	local run_seed="${seed:-$(date +%s%N)}"
+	local seed_args=(--seed "$run_seed")
+	local population_args=()
+	if [[ -n "$population" ]]; then
+		population_args=(--population "$population")
+	fi
+	local client_args=(
+		--clients "$clients"
+		--balance "$balance"
+		"${seed_args[@]}"
+		"${population_args[@]}"
+	)
+
+	# The server ignores self-declared balances, so hand it the stake
+	# distribution up front.
+	if ! (
+		cd "$ROOT_DIR"
+		GOCACHE="$CACHE_DIR" GOPATH="$GOPATH_DIR" go run ./tools/client "${client_args[@]}" --write-genesis "$genesis_file"
+	); then
+		echo "Failed to write genesis file for $variant" >&2
+		return 1
+	fi
+	echo "Genesis file: $genesis_file"
+
 	(
 		cd "$variant_dir"
-		PORT="$port" GOCACHE="$CACHE_DIR" GOPATH="$GOPATH_DIR" go run .
+		PORT="$port" GOCACHE="$CACHE_DIR" GOPATH="$GOPATH_DIR" go run . "${seed_args[@]}" --genesis "$genesis_file" --faucet 0
 	) &>"$server_log" &
 	SERVER_PID=$!
 
@@ -218,12 +259,11 @@ run_variant() {
 		GOCACHE="$CACHE_DIR" GOPATH="$GOPATH_DIR" go run ./tools/client \
 			--host "$host" \
 			--port "$port" \
-			--clients "$clients" \
-			--balance "$balance" \
 			--base-cost "$base_cost" \
 			--round "$round_interval" \
 			--inter-delay "$inter_delay" \
-			--duration "$duration"
+			--duration "$duration" \
+			"${client_args[@]}"
 	) &>"$client_log" &
 	CLIENT_PID=$!
 
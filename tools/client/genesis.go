@@ -0,0 +1,37 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+
+	"simulation/engine"
+)
+
+// writeGenesis saves every validator's public key and balance as a genesis
+// file for the server's -genesis flag. The server no longer trusts the
+// balance a client declares, so this is how a population's stake
+// distribution reaches it.
+func writeGenesis(path string, specs []clientSpec, cfg config) error {
+	file := engine.GenesisFile{}
+	for _, spec := range specs {
+		key, err := validatorKey(cfg.keyDir, spec)
+		if err != nil {
+			return err
+		}
+		stake := spec.balance
+		if stake < 1 {
+			stake = 1
+		}
+		file.Allocations = append(file.Allocations, engine.Allocation{
+			PublicKey: hex.EncodeToString(key.Public().(ed25519.PublicKey)),
+			Stake:     stake,
+		})
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
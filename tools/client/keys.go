@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// validatorKey returns the Ed25519 key of a simulated validator. With a key
+// directory the key is loaded from validator-<id>.key, or generated and saved
+// there on first use; otherwise it is derived from the validator's seed so
+// identities are reproducible without touching the disk.
+func validatorKey(dir string, spec clientSpec) (ed25519.PrivateKey, error) {
+	derived := sha256.Sum256([]byte("validator-key|" + strconv.FormatInt(spec.seed, 10)))
+	if dir == "" {
+		return ed25519.NewKeyFromSeed(derived[:]), nil
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("validator-%d.key", spec.id))
+	data, err := os.ReadFile(path)
+	if err == nil {
+		seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("%s: not a hex encoded %d byte seed", path, ed25519.SeedSize)
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(derived[:])+"\n"), 0o600); err != nil {
+		return nil, err
+	}
+	return ed25519.NewKeyFromSeed(derived[:]), nil
+}
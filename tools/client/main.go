@@ -1,15 +1,18 @@
 package main
 
 import (
-	"bufio"
+	"crypto/ed25519"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"math/rand"
 	"net"
+	"os"
+	"strings"
 	"sync"
 	"time"
+
+	"simulation/engine"
 )
 
 type config struct {
@@ -25,28 +28,66 @@ type config struct {
 	roundDuration time.Duration
 	trialDuration time.Duration
 	seed          int64
+	strategies    []string
+	params        strategyParams
+	population    string
+	protocol      string
+	keyDir        string
+	reconnect     bool
+	sealed        bool
+	bond          float64
+	commission    int
+	genesisOut    string
 }
 
 func main() {
 	cfg := parseFlags()
-	rand.Seed(cfg.seed)
+
+	specs := specsFromFlags(cfg)
+	if cfg.population != "" {
+		pop, err := loadPopulation(cfg.population)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if specs, err = specsFromPopulation(pop, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	if cfg.genesisOut != "" {
+		if err := writeGenesis(cfg.genesisOut, specs, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	longestRound := cfg.roundDuration
+	for _, spec := range specs {
+		if spec.roundDuration > longestRound {
+			longestRound = spec.roundDuration
+		}
+	}
 
 	addr := fmt.Sprintf("%s:%d", cfg.host, cfg.port)
 	deadline := time.Now().Add(cfg.trialDuration)
-	ctxTimeout := cfg.trialDuration + cfg.roundDuration + time.Second
+	ctxTimeout := cfg.trialDuration + longestRound + time.Second
 
 	var wg sync.WaitGroup
-	logCh := make(chan string, cfg.clients*2)
+	logCh := make(chan string, len(specs)*2+1)
 	stopLog := make(chan struct{})
 
 	go logger(logCh, stopLog)
+	logCh <- fmt.Sprintf("[runner] seed %d", cfg.seed)
 
-	for i := 0; i < cfg.clients; i++ {
+	for _, spec := range specs {
 		wg.Add(1)
-		go func(id int) {
+		go func(spec clientSpec) {
 			defer wg.Done()
-			runClient(id, addr, cfg, deadline, logCh)
-		}(i)
+			runClient(spec, addr, cfg, deadline, logCh)
+		}(spec)
 	}
 
 	// Wait for clients with a safety timeout in case something wedges.
@@ -84,10 +125,55 @@ func parseFlags() config {
 	flag.Float64Var(&interDelaySec, "inter-delay", 1.0, "delay in seconds between BPM and bid submissions")
 	flag.Float64Var(&roundDurationSec, "round", 60.0, "seconds between successive bids from the same validator")
 	flag.Float64Var(&trialDurationSec, "duration", 300.0, "total experiment duration in seconds")
-	flag.Int64Var(&cfg.seed, "seed", time.Now().UnixNano(), "seed for the shared RNG")
+	flag.Int64Var(&cfg.seed, "seed", time.Now().UnixNano(), "master seed from which every validator's RNG stream is derived")
+
+	var strategyList string
+	var paramSpec string
+	flag.StringVar(&strategyList, "strategy", "overbidder", "comma separated bidding strategies assigned to clients round-robin ("+strategyNames()+")")
+	flag.StringVar(&paramSpec, "strategy-params", "", "strategy parameters as key=value,... (value, shade, fraction, base-cost, overbid-limit)")
+	flag.StringVar(&cfg.protocol, "protocol", "jsonl", "wire protocol: jsonl (typed messages) or text (legacy prompts)")
+	flag.StringVar(&cfg.keyDir, "keys", "", "directory of per-validator Ed25519 keys, created on first use (default: derive keys from the seed)")
+	flag.BoolVar(&cfg.sealed, "sealed", false, "commit to bids and reveal them after the round closes, for servers run with -commit-reveal (jsonl only)")
+	flag.Float64Var(&cfg.bond, "bond", 0, "share of its balance each validator bonds after registering, for servers run with -bonded-stake (jsonl only)")
+	flag.IntVar(&cfg.commission, "commission", 0, "commission in basis points each validator charges its delegators, for servers run with -bonded-stake (jsonl only)")
+	flag.BoolVar(&cfg.reconnect, "reconnect", false, "resume the validator's account after a lost connection (jsonl only)")
+	flag.StringVar(&cfg.genesisOut, "write-genesis", "", "write the validators' keys and balances as a server genesis file to this path and exit")
+	flag.StringVar(&cfg.population, "population", "", "JSON population file describing validator groups (overrides --clients, --balance and --strategy)")
 
 	flag.Parse()
 
+	for _, name := range strings.Split(strategyList, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := strategies[name]; !ok {
+			fmt.Fprintf(os.Stderr, "unknown strategy %q (available: %s)\n", name, strategyNames())
+			os.Exit(2)
+		}
+		cfg.strategies = append(cfg.strategies, name)
+	}
+	params, err := parseStrategyParams(paramSpec)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	cfg.params = params
+
+	if cfg.reconnect && cfg.protocol == "text" {
+		fmt.Fprintln(os.Stderr, "--reconnect needs --protocol jsonl")
+		os.Exit(2)
+	}
+	if cfg.sealed && cfg.protocol == "text" {
+		fmt.Fprintln(os.Stderr, "--sealed needs --protocol jsonl")
+		os.Exit(2)
+	}
+	if cfg.bond > 0 && cfg.protocol == "text" {
+		fmt.Fprintln(os.Stderr, "--bond needs --protocol jsonl")
+		os.Exit(2)
+	}
+	if cfg.commission > 0 && cfg.protocol == "text" {
+		fmt.Fprintln(os.Stderr, "--commission needs --protocol jsonl")
+		os.Exit(2)
+	}
+
 	cfg.interDelay = time.Duration(interDelaySec * float64(time.Second))
 	cfg.roundDuration = time.Duration(roundDurationSec * float64(time.Second))
 	cfg.trialDuration = time.Duration(trialDurationSec * float64(time.Second))
@@ -107,71 +193,101 @@ func parseFlags() config {
 	return cfg
 }
 
-func runClient(id int, addr string, cfg config, deadline time.Time, logCh chan<- string) {
-	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+func runClient(spec clientSpec, addr string, cfg config, deadline time.Time, logCh chan<- string) {
+	id := spec.id
+
+	key, err := validatorKey(cfg.keyDir, spec)
 	if err != nil {
-		logCh <- fmt.Sprintf("[client-%d] dial error: %v", id, err)
+		logCh <- fmt.Sprintf("[client-%d] key error: %v", id, err)
 		return
 	}
-	defer conn.Close()
-
-	if err := conn.SetDeadline(deadline.Add(cfg.interDelay * 2)); err != nil {
-		logCh <- fmt.Sprintf("[client-%d] set deadline error: %v", id, err)
-	}
-
-	go io.Copy(ioutil.Discard, conn) // drain server announcements to avoid blocking
+	// Each validator owns a stream derived from the master seed, so its bids,
+	// BPMs, personality and stagger do not depend on goroutine scheduling.
+	rng := rand.New(rand.NewSource(spec.seed))
 
-	writer := bufio.NewWriter(conn)
-	rng := rand.New(rand.NewSource(time.Now().UnixNano() + int64(id)*7919))
-
-	initialBalance := cfg.balance
+	initialBalance := spec.balance
 	if initialBalance < 1 {
 		initialBalance = 1
 	}
 
-	if err := sendLine(writer, initialBalance); err != nil {
-		logCh <- fmt.Sprintf("[client-%d] send balance error: %v", id, err)
+	obs := newObserver(initialBalance)
+	conn, tr, lost, err := connect(addr, cfg, deadline, key, obs, initialBalance, false)
+	if err != nil {
+		logCh <- fmt.Sprintf("[client-%d] %v", id, err)
 		return
 	}
+	defer func() { conn.Close() }()
 
 	// Randomize the initial scheduling slightly so that clients stagger naturally.
 	initialSleep := time.Duration(rng.Intn(500)) * time.Millisecond
 	time.Sleep(initialSleep)
 
-	overbidPercent := rng.Intn(cfg.overbidLimit) + 1
-	overbidActive := rng.Intn(cfg.overbidLimit) < overbidPercent
+	strategy, err := newStrategy(spec.strategy, cfg, spec.params, rng)
+	if err != nil {
+		logCh <- fmt.Sprintf("[client-%d] %v", id, err)
+		return
+	}
+	logCh <- fmt.Sprintf("[client-%d] group %s, strategy %s, balance %d", id, spec.group, strategy.Name(), obs.snapshot().Balance)
 
-	doRound := func() error {
-		bpm := cfg.minBPM + rng.Intn(cfg.maxBPM-cfg.minBPM+1)
-		if err := sendLine(writer, bpm); err != nil {
-			return fmt.Errorf("send BPM: %w", err)
+	if jt, ok := tr.(*jsonTransport); ok && cfg.bond > 0 {
+		state := obs.snapshot()
+		if err := jt.stake(state, engine.BondStake, int(cfg.bond*float64(state.Balance))); err != nil {
+			logCh <- fmt.Sprintf("[client-%d] bond: %v", id, err)
+			return
 		}
-		time.Sleep(cfg.interDelay)
-
-		bid := rng.Intn(cfg.baseCost) + 1
-		if overbidActive {
-			overbidCheck := rng.Intn(cfg.overbidLimit) + 1
-			if overbidCheck <= overbidPercent {
-				bid = cfg.baseCost + rng.Intn(cfg.baseCost) + 1
-			}
+	}
+	if jt, ok := tr.(*jsonTransport); ok && cfg.commission > 0 {
+		if err := jt.stake(obs.snapshot(), engine.SetCommission, cfg.commission); err != nil {
+			logCh <- fmt.Sprintf("[client-%d] commission: %v", id, err)
+			return
 		}
+	}
 
-		if err := sendLine(writer, bid); err != nil {
-			return fmt.Errorf("send bid: %w", err)
+	doRound := func() error {
+		state := obs.snapshot()
+		bpm, bid := strategy.Next(state, rng)
+		if bid < state.Reserve {
+			// The server would reject it; sit the round out instead.
+			return nil
+		}
+		obs.recordBid(bid)
+		if err := tr.bid(state, bpm, bid); err != nil {
+			return err
 		}
 
 		return nil
 	}
 
-	if err := doRound(); err != nil {
-		logCh <- fmt.Sprintf("[client-%d] initial round error: %v", id, err)
+	// reconnect replaces a lost connection with a resumed one. It reports
+	// false when the client should give up instead.
+	reconnect := func(cause error) bool {
+		if !cfg.reconnect || !time.Now().Before(deadline) {
+			logCh <- fmt.Sprintf("[client-%d] connection lost: %v", id, cause)
+			return false
+		}
+		conn.Close()
+		for attempt := 1; attempt <= reconnectAttempts && time.Now().Before(deadline); attempt++ {
+			time.Sleep(time.Duration(attempt) * time.Second)
+			c, t, l, err := connect(addr, cfg, deadline, key, obs, initialBalance, true)
+			if err != nil {
+				logCh <- fmt.Sprintf("[client-%d] resume attempt %d: %v", id, attempt, err)
+				continue
+			}
+			conn, tr, lost = c, t, l
+			logCh <- fmt.Sprintf("[client-%d] resumed after: %v", id, cause)
+			return true
+		}
+		return false
+	}
+
+	if err := doRound(); err != nil && !reconnect(fmt.Errorf("initial round: %w", err)) {
 		return
 	}
 
 	deadlineTimer := time.NewTimer(time.Until(deadline))
 	defer deadlineTimer.Stop()
 
-	intervalTicker := time.NewTicker(cfg.roundDuration)
+	intervalTicker := time.NewTicker(spec.roundDuration)
 	defer intervalTicker.Stop()
 
 	for {
@@ -179,20 +295,92 @@ func runClient(id int, addr string, cfg config, deadline time.Time, logCh chan<-
 		case <-deadlineTimer.C:
 			logCh <- fmt.Sprintf("[client-%d] completed", id)
 			return
+		case <-lost:
+			if !reconnect(errors.New("server closed the connection")) {
+				return
+			}
 		case <-intervalTicker.C:
-			if err := doRound(); err != nil {
-				logCh <- fmt.Sprintf("[client-%d] round error: %v", id, err)
+			if err := doRound(); err != nil && !reconnect(err) {
 				return
 			}
+		case round := <-obs.closed:
+			if jt, ok := tr.(*jsonTransport); ok && cfg.sealed {
+				if err := jt.reveal(obs.snapshot(), round); err != nil && !reconnect(err) {
+					return
+				}
+			}
 		}
 	}
 }
 
-func sendLine(w *bufio.Writer, value int) error {
-	if _, err := fmt.Fprintf(w, "%d\n", value); err != nil {
-		return err
+// reconnectAttempts is how often a --reconnect client tries to resume before
+// giving up, waiting one second longer after each failure.
+const reconnectAttempts = 5
+
+// connect dials the server and registers the validator, or with resume set
+// reattaches it to the account it registered earlier. The returned channel is
+// closed when the server side of the connection goes away.
+func connect(addr string, cfg config, deadline time.Time, key ed25519.PrivateKey, obs *observer, balance int, resume bool) (net.Conn, transport, <-chan struct{}, error) {
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("dial error: %w", err)
+	}
+	if err := conn.SetDeadline(deadline.Add(cfg.interDelay * 2)); err != nil {
+		conn.Close()
+		return nil, nil, nil, fmt.Errorf("set deadline error: %w", err)
+	}
+
+	tr, err := newTransport(cfg.protocol, conn, cfg.interDelay, key, cfg.sealed)
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+
+	obs.attach()
+	lost := make(chan struct{})
+	go func() {
+		if cfg.protocol == "text" {
+			obs.watch(conn)
+		} else {
+			obs.watchJSON(conn)
+		}
+		close(lost)
+	}()
+
+	if resume {
+		jt := tr.(*jsonTransport)
+		if err := jt.hello(); err != nil {
+			conn.Close()
+			return nil, nil, nil, err
+		}
+		var challenge string
+		select {
+		case challenge = <-obs.challenge:
+		case <-time.After(5 * time.Second):
+			conn.Close()
+			return nil, nil, nil, errors.New("no hello from server")
+		}
+		err = jt.resume(challenge)
+	} else {
+		err = tr.register(balance)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, fmt.Errorf("send registration error: %w", err)
+	}
+
+	if cfg.protocol != "text" {
+		select {
+		case <-obs.registered:
+		case <-lost:
+			conn.Close()
+			return nil, nil, nil, errors.New("registration rejected by server")
+		case <-time.After(5 * time.Second):
+			conn.Close()
+			return nil, nil, nil, errors.New("no registration from server")
+		}
 	}
-	return w.Flush()
+	return conn, tr, lost, nil
 }
 
 func logger(ch <-chan string, done chan<- struct{}) {
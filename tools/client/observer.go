@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"simulation/engine"
+)
+
+// observer reads the server's announcements and keeps the State a strategy
+// bases its decisions on.
+type observer struct {
+	mu    sync.Mutex
+	state State
+	// registered is closed once the server has assigned an address, and
+	// challenge receives the resume challenge from the server hello. Both
+	// are replaced by attach for every new connection.
+	registered chan struct{}
+	challenge  chan string
+	// closed receives the number of every round that closes for bids.
+	closed chan int
+}
+
+func newObserver(balance int) *observer {
+	o := &observer{state: State{Balance: balance}, closed: make(chan int, 4)}
+	o.attach()
+	return o
+}
+
+// attach prepares the observer for a new connection. The State, including
+// the bid history, carries over.
+func (o *observer) attach() {
+	o.registered = make(chan struct{})
+	o.challenge = make(chan string, 1)
+}
+
+// watch consumes the connection until it closes. Lines that are not round
+// results or balance updates (prompts, chain dumps) are ignored.
+func (o *observer) watch(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "winning validator:"):
+			o.recordWinner(strings.TrimSpace(strings.TrimPrefix(line, "winning validator:")))
+		case strings.HasPrefix(line, "clearing price:"):
+			if price, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "clearing price:"))); err == nil {
+				o.recordPrice(price)
+			}
+		case strings.HasPrefix(line, "Your current balance:"):
+			if balance, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "Your current balance:"))); err == nil {
+				o.mu.Lock()
+				o.state.Balance = balance
+				o.mu.Unlock()
+			}
+		}
+	}
+}
+
+// watchJSON consumes a JSON-lines connection until it closes. Anything that
+// is not a JSON object, such as the initial legacy prompt, is skipped.
+func (o *observer) watchJSON(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		var msg engine.Message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			continue
+		}
+		switch msg.Type {
+		case engine.MsgHello:
+			select {
+			case o.challenge <- msg.Challenge:
+			default:
+			}
+		case engine.MsgRegistered:
+			o.mu.Lock()
+			o.state.Address = msg.Address
+			o.state.Balance = msg.Balance
+			o.state.Round = msg.Round
+			o.state.Reserve = msg.Reserve
+			o.state.Seed, _ = hex.DecodeString(msg.Seed)
+			o.state.Nonce = msg.Nonce
+			o.mu.Unlock()
+			close(o.registered)
+		case engine.MsgRoundOpen:
+			o.mu.Lock()
+			o.state.Round = msg.Round
+			o.state.Reserve = msg.Reserve
+			o.state.Seed, _ = hex.DecodeString(msg.Seed)
+			o.mu.Unlock()
+		case engine.MsgRoundClose:
+			select {
+			case o.closed <- msg.Round:
+			default:
+			}
+		case engine.MsgRoundResult:
+			o.recordWinner(msg.Winner)
+			o.recordPrice(msg.Price)
+		case engine.MsgBalance:
+			o.mu.Lock()
+			o.state.Balance = msg.Balance
+			o.mu.Unlock()
+		case engine.MsgStake:
+			o.mu.Lock()
+			o.state.Balance = msg.Balance
+			o.state.Bonded = msg.Bonded
+			o.mu.Unlock()
+		case engine.MsgBidRejected:
+			o.recordRejection(msg.Reason)
+		}
+	}
+}
+
+func (o *observer) recordRejection(reason string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if n := len(o.state.History); n > 0 {
+		o.state.History[n-1].Rejected = reason
+	}
+}
+
+func (o *observer) recordBid(bid int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.state.History = append(o.state.History, RoundRecord{Bid: bid})
+	if len(o.state.History) > historyLimit {
+		o.state.History = o.state.History[len(o.state.History)-historyLimit:]
+	}
+}
+
+func (o *observer) recordWinner(winner string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.state.LastWinner = winner
+	o.state.LastPrice = 0
+	if n := len(o.state.History); n > 0 {
+		o.state.History[n-1].Winner = winner
+		o.state.History[n-1].Price = 0
+	}
+}
+
+func (o *observer) recordPrice(price int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.state.LastPrice = price
+	if n := len(o.state.History); n > 0 {
+		o.state.History[n-1].Price = price
+	}
+}
+
+// snapshot returns a copy of the current state.
+func (o *observer) snapshot() State {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	state := o.state
+	state.History = append([]RoundRecord(nil), o.state.History...)
+	return state
+}
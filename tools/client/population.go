@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+
+	"simulation/engine"
+)
+
+// Population describes a heterogeneous validator fleet, loaded from the JSON
+// file given with --population:
+//
+//	{
+//	  "groups": [
+//	    {"name": "whales", "count": 3, "balance": {"dist": "fixed", "value": 50000},
+//	     "strategy": "stake", "params": {"fraction": 0.02}},
+//	    {"name": "small", "count": 40, "balance": {"dist": "pareto", "min": 100, "alpha": 1.5},
+//	     "strategy": "overbidder", "round": 30, "seed": 7}
+//	  ]
+//	}
+type Population struct {
+	Groups []Group `json:"groups"`
+}
+
+// Group is a set of validators sharing a stake distribution, a strategy and
+// a bidding cadence.
+type Group struct {
+	Name     string             `json:"name"`
+	Count    int                `json:"count"`
+	Balance  BalanceSpec        `json:"balance"`
+	Strategy string             `json:"strategy"`
+	Params   map[string]float64 `json:"params"`
+	// Round is the number of seconds between bids; zero uses --round.
+	Round float64 `json:"round"`
+	// Seed overrides the group's stream, which is otherwise derived from
+	// the client --seed and the group's position in the file. It is mixed
+	// with the group's name, so groups sharing a seed still get their own
+	// validators and keys.
+	Seed *int64 `json:"seed"`
+}
+
+// BalanceSpec is the distribution a group's starting balances are drawn
+// from: "fixed" (value), "uniform" (min, max) or "pareto" (min, alpha, and an
+// optional max cap).
+type BalanceSpec struct {
+	Dist  string  `json:"dist"`
+	Value int     `json:"value"`
+	Min   int     `json:"min"`
+	Max   int     `json:"max"`
+	Alpha float64 `json:"alpha"`
+}
+
+// clientSpec is everything one simulated validator needs to run.
+type clientSpec struct {
+	id            int
+	group         string
+	balance       int
+	strategy      string
+	params        strategyParams
+	roundDuration time.Duration
+	seed          int64
+}
+
+func loadPopulation(path string) (Population, error) {
+	var pop Population
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return pop, err
+	}
+	if err := json.Unmarshal(data, &pop); err != nil {
+		return pop, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(pop.Groups) == 0 {
+		return pop, fmt.Errorf("%s: no groups defined", path)
+	}
+	names := make(map[string]bool, len(pop.Groups))
+	for i, group := range pop.Groups {
+		if group.Count <= 0 {
+			return pop, fmt.Errorf("%s: group %d has no validators", path, i)
+		}
+		if group.Strategy == "" {
+			pop.Groups[i].Strategy = "overbidder"
+		}
+		if _, ok := strategies[pop.Groups[i].Strategy]; !ok {
+			return pop, fmt.Errorf("%s: group %d: unknown strategy %q (available: %s)", path, i, group.Strategy, strategyNames())
+		}
+		if group.Name == "" {
+			pop.Groups[i].Name = fmt.Sprintf("group-%d", i)
+		}
+		if names[pop.Groups[i].Name] {
+			return pop, fmt.Errorf("%s: group %d: duplicate name %q", path, i, pop.Groups[i].Name)
+		}
+		names[pop.Groups[i].Name] = true
+	}
+	return pop, nil
+}
+
+// specsFromPopulation expands every group into individual clients. Balances
+// are drawn from a per-group stream so that adding a group leaves the others
+// unchanged.
+func specsFromPopulation(pop Population, cfg config) ([]clientSpec, error) {
+	var specs []clientSpec
+	for gi, group := range pop.Groups {
+		groupSeed := engine.DeriveSeed(cfg.seed, int64(gi))
+		if group.Seed != nil {
+			groupSeed = engine.DeriveSeed(*group.Seed, nameStream(group.Name))
+		}
+		balanceRNG := rand.New(rand.NewSource(groupSeed))
+
+		params := strategyParams{}
+		for key, value := range cfg.params {
+			params[key] = value
+		}
+		for key, value := range group.Params {
+			params[key] = value
+		}
+
+		roundDuration := cfg.roundDuration
+		if group.Round > 0 {
+			roundDuration = time.Duration(group.Round * float64(time.Second))
+		}
+
+		for i := 0; i < group.Count; i++ {
+			balance, err := group.Balance.draw(balanceRNG, cfg.balance)
+			if err != nil {
+				return nil, fmt.Errorf("group %s: %w", group.Name, err)
+			}
+			specs = append(specs, clientSpec{
+				id:            len(specs),
+				group:         group.Name,
+				balance:       balance,
+				strategy:      group.Strategy,
+				params:        params,
+				roundDuration: roundDuration,
+				seed:          engine.DeriveSeed(groupSeed, int64(i)+1),
+			})
+		}
+	}
+	return specs, nil
+}
+
+// nameStream maps a group name to a stream number for DeriveSeed.
+func nameStream(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// specsFromFlags builds the homogeneous fleet described by the command line.
+func specsFromFlags(cfg config) []clientSpec {
+	specs := make([]clientSpec, cfg.clients)
+	for i := range specs {
+		specs[i] = clientSpec{
+			id:            i,
+			group:         "default",
+			balance:       cfg.balance,
+			strategy:      cfg.strategies[i%len(cfg.strategies)],
+			params:        cfg.params,
+			roundDuration: cfg.roundDuration,
+			seed:          engine.DeriveSeed(cfg.seed, int64(i)),
+		}
+	}
+	return specs
+}
+
+func (b BalanceSpec) draw(rng *rand.Rand, fallback int) (int, error) {
+	var balance int
+	switch b.Dist {
+	case "", "fixed":
+		balance = b.Value
+		if balance == 0 {
+			balance = fallback
+		}
+	case "uniform":
+		if b.Max < b.Min {
+			return 0, fmt.Errorf("uniform balance needs min <= max")
+		}
+		balance = b.Min + rng.Intn(b.Max-b.Min+1)
+	case "pareto":
+		if b.Min <= 0 || b.Alpha <= 0 {
+			return 0, fmt.Errorf("pareto balance needs min > 0 and alpha > 0")
+		}
+		// Inverse transform sampling: x = min / U^(1/alpha).
+		u := 1 - rng.Float64()
+		balance = int(float64(b.Min) / math.Pow(u, 1/b.Alpha))
+		if b.Max > 0 && balance > b.Max {
+			balance = b.Max
+		}
+	default:
+		return 0, fmt.Errorf("unknown balance distribution %q", b.Dist)
+	}
+	if balance < 1 {
+		balance = 1
+	}
+	return balance, nil
+}
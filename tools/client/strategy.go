@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// historyLimit bounds how many past rounds a validator remembers.
+const historyLimit = 100
+
+// RoundRecord is one round as seen by a single validator.
+type RoundRecord struct {
+	Bid    int
+	Winner string
+	Price  int
+	// Rejected holds the server's reason code when the bid was refused.
+	Rejected string
+}
+
+// State is everything a validator knows when it decides on its next bid.
+type State struct {
+	Address string
+	// Round is the round currently collecting bids, Reserve its minimum bid,
+	// Seed its sortition seed and Nonce the last bid nonce the server
+	// accepted; all are only known in JSON-lines mode.
+	Round      int
+	Reserve    int
+	Seed       []byte
+	Nonce      uint64
+	Balance    int
+	Bonded     int
+	LastWinner string
+	LastPrice  int
+	History    []RoundRecord
+}
+
+// Strategy decides the BPM and bid a validator submits in its next round.
+type Strategy interface {
+	Name() string
+	Next(state State, rng *rand.Rand) (bpm, bid int)
+}
+
+// strategyParams holds the key=value parameters of a strategy.
+type strategyParams map[string]float64
+
+func (p strategyParams) get(key string, fallback float64) float64 {
+	if value, ok := p[key]; ok {
+		return value
+	}
+	return fallback
+}
+
+// parseStrategyParams parses "key=value,key=value".
+func parseStrategyParams(spec string) (strategyParams, error) {
+	params := strategyParams{}
+	if strings.TrimSpace(spec) == "" {
+		return params, nil
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("strategy parameter %q is not key=value", pair)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("strategy parameter %q: %w", pair, err)
+		}
+		params[strings.TrimSpace(kv[0])] = value
+	}
+	return params, nil
+}
+
+type strategyFactory func(cfg config, params strategyParams, rng *rand.Rand) Strategy
+
+var strategies = map[string]strategyFactory{
+	"truthful":   newTruthful,
+	"shade":      newShade,
+	"stake":      newStakeFraction,
+	"overbidder": newOverbidder,
+}
+
+// strategyNames lists the built-in strategies for help messages.
+func strategyNames() string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// newStrategy builds the named strategy. Personality traits are drawn from rng
+// so they are reproducible from the client seed.
+func newStrategy(name string, cfg config, params strategyParams, rng *rand.Rand) (Strategy, error) {
+	factory, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q (available: %s)", name, strategyNames())
+	}
+	return factory(cfg, params, rng), nil
+}
+
+// bpmRange draws the BPM every built-in strategy submits with its bid.
+type bpmRange struct {
+	min, max int
+}
+
+func (r bpmRange) draw(rng *rand.Rand) int {
+	return r.min + rng.Intn(r.max-r.min+1)
+}
+
+func capBid(bid, balance int) int {
+	if balance > 0 && bid > balance {
+		bid = balance
+	}
+	if bid < 1 {
+		bid = 1
+	}
+	return bid
+}
+
+// truthful always bids its private valuation of a block ("value", default
+// base-cost), which is the dominant strategy in a second-price auction.
+type truthful struct {
+	bpm   bpmRange
+	value int
+}
+
+func newTruthful(cfg config, params strategyParams, rng *rand.Rand) Strategy {
+	return &truthful{
+		bpm:   bpmRange{cfg.minBPM, cfg.maxBPM},
+		value: int(params.get("value", float64(cfg.baseCost))),
+	}
+}
+
+func (s *truthful) Name() string { return "truthful" }
+
+func (s *truthful) Next(state State, rng *rand.Rand) (int, int) {
+	return s.bpm.draw(rng), capBid(s.value, state.Balance)
+}
+
+// shade bids its valuation ("value", default base-cost) minus a constant
+// "shade" (default 1).
+type shade struct {
+	bpm   bpmRange
+	value int
+	shade int
+}
+
+func newShade(cfg config, params strategyParams, rng *rand.Rand) Strategy {
+	return &shade{
+		bpm:   bpmRange{cfg.minBPM, cfg.maxBPM},
+		value: int(params.get("value", float64(cfg.baseCost))),
+		shade: int(params.get("shade", 1)),
+	}
+}
+
+func (s *shade) Name() string { return "shade" }
+
+func (s *shade) Next(state State, rng *rand.Rand) (int, int) {
+	return s.bpm.draw(rng), capBid(s.value-s.shade, state.Balance)
+}
+
+// stakeFraction bids a fixed "fraction" (default 0.01) of its current
+// balance.
+type stakeFraction struct {
+	bpm      bpmRange
+	fraction float64
+}
+
+func newStakeFraction(cfg config, params strategyParams, rng *rand.Rand) Strategy {
+	return &stakeFraction{
+		bpm:      bpmRange{cfg.minBPM, cfg.maxBPM},
+		fraction: params.get("fraction", 0.01),
+	}
+}
+
+func (s *stakeFraction) Name() string { return "stake" }
+
+func (s *stakeFraction) Next(state State, rng *rand.Rand) (int, int) {
+	return s.bpm.draw(rng), capBid(int(s.fraction*float64(state.Balance)), state.Balance)
+}
+
+// overbidder is the original client behaviour: bids are uniform in
+// [1, base-cost], and validators with an overbidding personality sometimes
+// bid in [base-cost+1, 2*base-cost] instead.
+type overbidder struct {
+	bpm            bpmRange
+	baseCost       int
+	overbidLimit   int
+	overbidPercent int
+	overbidActive  bool
+}
+
+func newOverbidder(cfg config, params strategyParams, rng *rand.Rand) Strategy {
+	s := &overbidder{
+		bpm:          bpmRange{cfg.minBPM, cfg.maxBPM},
+		baseCost:     int(params.get("base-cost", float64(cfg.baseCost))),
+		overbidLimit: int(params.get("overbid-limit", float64(cfg.overbidLimit))),
+	}
+	if s.baseCost <= 0 {
+		s.baseCost = 1
+	}
+	if s.overbidLimit <= 0 {
+		s.overbidLimit = 100
+	}
+	s.overbidPercent = rng.Intn(s.overbidLimit) + 1
+	s.overbidActive = rng.Intn(s.overbidLimit) < s.overbidPercent
+	return s
+}
+
+func (s *overbidder) Name() string { return "overbidder" }
+
+func (s *overbidder) Next(state State, rng *rand.Rand) (int, int) {
+	bpm := s.bpm.draw(rng)
+
+	bid := rng.Intn(s.baseCost) + 1
+	if s.overbidActive {
+		overbidCheck := rng.Intn(s.overbidLimit) + 1
+		if overbidCheck <= s.overbidPercent {
+			bid = s.baseCost + rng.Intn(s.baseCost) + 1
+		}
+	}
+
+	return bpm, bid
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"simulation/engine"
+)
+
+// transport sends a validator's registration and bids using one of the
+// protocols the server understands.
+type transport interface {
+	register(balance int) error
+	bid(state State, bpm, bid int) error
+}
+
+// newTransport builds the transport for protocol. key is only used by the
+// JSON-lines protocol, which signs every bid and, with sealed set, commits to
+// bids instead of sending them.
+func newTransport(protocol string, conn net.Conn, interDelay time.Duration, key ed25519.PrivateKey, sealed bool) (transport, error) {
+	switch protocol {
+	case "text":
+		return &textTransport{w: bufio.NewWriter(conn), interDelay: interDelay}, nil
+	case engine.ProtocolJSONL:
+		return &jsonTransport{enc: json.NewEncoder(conn), key: key, sealed: sealed}, nil
+	default:
+		return nil, fmt.Errorf("unknown protocol %q (available: text, jsonl)", protocol)
+	}
+}
+
+// textTransport answers the legacy prompts, pausing interDelay between the
+// BPM and the bid like a human typing into nc.
+type textTransport struct {
+	w          *bufio.Writer
+	interDelay time.Duration
+}
+
+func (t *textTransport) register(balance int) error {
+	return sendLine(t.w, balance)
+}
+
+func (t *textTransport) bid(state State, bpm, bid int) error {
+	if err := sendLine(t.w, bpm); err != nil {
+		return fmt.Errorf("send BPM: %w", err)
+	}
+	time.Sleep(t.interDelay)
+
+	if err := sendLine(t.w, bid); err != nil {
+		return fmt.Errorf("send bid: %w", err)
+	}
+	return nil
+}
+
+func sendLine(w *bufio.Writer, value int) error {
+	if _, err := fmt.Fprintf(w, "%d\n", value); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// jsonTransport negotiates the JSON-lines protocol, registers the
+// validator's public key and signs every bid with it.
+type jsonTransport struct {
+	enc    *json.Encoder
+	key    ed25519.PrivateKey
+	nonce  uint64
+	sealed bool
+	// pending is the last commitment, kept until its round closes.
+	pending *engine.BidRequest
+}
+
+func (j *jsonTransport) hello() error {
+	if err := j.enc.Encode(engine.Message{Type: engine.MsgHello, Protocol: engine.ProtocolJSONL}); err != nil {
+		return fmt.Errorf("send hello: %w", err)
+	}
+	return nil
+}
+
+func (j *jsonTransport) register(balance int) error {
+	if err := j.hello(); err != nil {
+		return err
+	}
+	pub := j.key.Public().(ed25519.PublicKey)
+	return j.enc.Encode(engine.Message{Type: engine.MsgRegister, Balance: balance, PublicKey: hex.EncodeToString(pub)})
+}
+
+// resume reattaches to the validator's existing account by signing the
+// challenge from the server hello. The caller must have sent hello first.
+func (j *jsonTransport) resume(challenge string) error {
+	pub := j.key.Public().(ed25519.PublicKey)
+	return j.enc.Encode(engine.Message{
+		Type:      engine.MsgResume,
+		PublicKey: hex.EncodeToString(pub),
+		Signature: hex.EncodeToString(engine.SignResume(j.key, challenge)),
+	})
+}
+
+func (j *jsonTransport) nextNonce(state State) uint64 {
+	if state.Nonce > j.nonce {
+		j.nonce = state.Nonce
+	}
+	j.nonce++
+	return j.nonce
+}
+
+func (j *jsonTransport) bid(state State, bpm, bid int) error {
+	req := engine.BidRequest{Address: state.Address, Round: state.Round, Nonce: j.nextNonce(state), BPM: bpm, Bid: bid}
+	if len(state.Seed) > 0 {
+		req.Proof, _ = engine.ProveSortition(j.key, state.Seed)
+	}
+	if j.sealed {
+		return j.commit(req)
+	}
+	engine.SignBid(j.key, &req)
+	msg := engine.Message{
+		Type:      engine.MsgBid,
+		Round:     req.Round,
+		Nonce:     req.Nonce,
+		BPM:       bpm,
+		Bid:       bid,
+		Proof:     hex.EncodeToString(req.Proof),
+		Signature: hex.EncodeToString(req.Signature),
+	}
+	if err := j.enc.Encode(msg); err != nil {
+		return fmt.Errorf("send bid: %w", err)
+	}
+	return nil
+}
+
+// stake sends a signed bond or unbond request for amount, or sets the
+// validator's commission to amount basis points.
+func (j *jsonTransport) stake(state State, kind engine.BidKind, amount int) error {
+	req := engine.BidRequest{Address: state.Address, Kind: kind, Nonce: j.nextNonce(state), Bid: amount}
+	engine.SignBid(j.key, &req)
+	msg := engine.Message{Type: engine.MsgBond, Nonce: req.Nonce, Amount: amount, Signature: hex.EncodeToString(req.Signature)}
+	switch kind {
+	case engine.UnbondStake:
+		msg.Type = engine.MsgUnbond
+	case engine.SetCommission:
+		msg.Type, msg.Amount, msg.Commission = engine.MsgCommission, 0, amount
+	}
+	if err := j.enc.Encode(msg); err != nil {
+		return fmt.Errorf("send %s: %w", msg.Type, err)
+	}
+	return nil
+}
+
+// commit sends H(address || round || bid || salt) for req and remembers the
+// bid and salt for the reveal.
+func (j *jsonTransport) commit(req engine.BidRequest) error {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	req.Kind = engine.CommitBid
+	req.Commitment = engine.Commitment(req.Address, req.Round, req.Bid, salt)
+	engine.SignBid(j.key, &req)
+	msg := engine.Message{
+		Type:       engine.MsgCommit,
+		Round:      req.Round,
+		Nonce:      req.Nonce,
+		BPM:        req.BPM,
+		Commitment: hex.EncodeToString(req.Commitment),
+		Proof:      hex.EncodeToString(req.Proof),
+		Signature:  hex.EncodeToString(req.Signature),
+	}
+	if err := j.enc.Encode(msg); err != nil {
+		return fmt.Errorf("send commit: %w", err)
+	}
+	req.Salt = salt
+	j.pending = &req
+	return nil
+}
+
+// reveal opens the commitment made for round, if there is one.
+func (j *jsonTransport) reveal(state State, round int) error {
+	if j.pending == nil || j.pending.Round != round {
+		return nil
+	}
+	req := *j.pending
+	j.pending = nil
+	req.Kind = engine.RevealBid
+	req.Nonce = j.nextNonce(state)
+	engine.SignBid(j.key, &req)
+	msg := engine.Message{
+		Type:      engine.MsgReveal,
+		Round:     req.Round,
+		Nonce:     req.Nonce,
+		Bid:       req.Bid,
+		Salt:      hex.EncodeToString(req.Salt),
+		Signature: hex.EncodeToString(req.Signature),
+	}
+	if err := j.enc.Encode(msg); err != nil {
+		return fmt.Errorf("send reveal: %w", err)
+	}
+	return nil
+}